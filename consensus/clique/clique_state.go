@@ -0,0 +1,193 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package clique
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"sort"
+
+	"github.com/alexlisong/go-nebulas/consensus/pb"
+	"github.com/alexlisong/go-nebulas/core"
+	"github.com/alexlisong/go-nebulas/storage"
+	"github.com/alexlisong/go-nebulas/util/byteutils"
+	"github.com/alexlisong/go-nebulas/util/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// cliqueSignersKeyPrefix namespaces the storage keys RootHash/newCliqueState
+// persist and look up a checkpoint's signer set under.
+var cliqueSignersKeyPrefix = []byte("clique.signers.")
+
+// signersStorageKey returns the storage key a signer set is recorded under
+// once its DynastyRoot (the sha256 of its canonical encoding, computed by
+// encodeSignersForRoot) is known.
+func signersStorageKey(root []byte) []byte {
+	return append(append([]byte{}, cliqueSignersKeyPrefix...), root...)
+}
+
+// encodeSignersForRoot canonically JSON-encodes signers (sorted, so the same
+// signer set always hashes to the same DynastyRoot regardless of map
+// iteration order) and returns the encoding alongside the content hash
+// RootHash records as DynastyRoot.
+func encodeSignersForRoot(signers []byteutils.Hash) ([]byte, []byte, error) {
+	addrs := make([]string, 0, len(signers))
+	for _, s := range signers {
+		addrs = append(addrs, s.Hex())
+	}
+	sort.Strings(addrs)
+
+	raw, err := json.Marshal(addrs)
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := sha256.Sum256(raw)
+	return raw, sum[:], nil
+}
+
+// cliqueState is the core.ConsensusState for a single checkpoint: a signer
+// set plus the timestamp it is being evaluated at, from which the round-robin
+// proposer for that moment is derived.
+type cliqueState struct {
+	engine    *Engine
+	stor      storage.Storage
+	signers   []byteutils.Hash // sorted, so the round-robin order is deterministic
+	timestamp int64
+}
+
+// newCliqueState reconstructs the signer set actually authorized at
+// consensusRoot's checkpoint, not whatever the engine's live signer set
+// happens to be right now: it looks up consensusRoot.DynastyRoot in stor,
+// which RootHash populated when that checkpoint was first computed. Only a
+// consensusRoot with no DynastyRoot yet (the engine computing its own next
+// state, not replaying a past one) falls back to the engine's current
+// signers.
+func newCliqueState(engine *Engine, consensusRoot *consensuspb.ConsensusRoot, stor storage.Storage) (*cliqueState, error) {
+	if consensusRoot != nil && len(consensusRoot.DynastyRoot) > 0 {
+		raw, err := stor.Get(signersStorageKey(consensusRoot.DynastyRoot))
+		if err != nil {
+			return nil, err
+		}
+		var addrs []string
+		if err := json.Unmarshal(raw, &addrs); err != nil {
+			return nil, err
+		}
+		signers := make([]byteutils.Hash, 0, len(addrs))
+		for _, s := range addrs {
+			addr, err := byteutils.FromHex(s)
+			if err != nil {
+				return nil, err
+			}
+			signers = append(signers, addr)
+		}
+		sort.Slice(signers, func(i, j int) bool { return signers[i].Hex() < signers[j].Hex() })
+
+		return &cliqueState{
+			engine:    engine,
+			stor:      stor,
+			signers:   signers,
+			timestamp: consensusRoot.Timestamp,
+		}, nil
+	}
+
+	engine.mu.RLock()
+	signers := make([]byteutils.Hash, 0, len(engine.signers))
+	for addrStr := range engine.signers {
+		addr, err := core.AddressParse(addrStr)
+		if err == nil {
+			signers = append(signers, byteutils.Hash(addr.Bytes()))
+		}
+	}
+	engine.mu.RUnlock()
+
+	sort.Slice(signers, func(i, j int) bool { return signers[i].Hex() < signers[j].Hex() })
+
+	timestamp := int64(0)
+	if consensusRoot != nil {
+		timestamp = consensusRoot.Timestamp
+	}
+
+	return &cliqueState{
+		engine:    engine,
+		stor:      stor,
+		signers:   signers,
+		timestamp: timestamp,
+	}, nil
+}
+
+// next returns the state elapsedSecondsInFuture seconds from now, with the
+// proposer recomputed for that slot.
+func (s *cliqueState) next(elapsedSecondsInFuture int64) (*cliqueState, error) {
+	return &cliqueState{
+		engine:    s.engine,
+		stor:      s.stor,
+		signers:   s.signers,
+		timestamp: s.timestamp + elapsedSecondsInFuture,
+	}, nil
+}
+
+// Proposer returns the signer whose turn it is at s.timestamp, rotating
+// round-robin through the sorted signer set the same way dpos indexes into
+// its dynasty slice.
+func (s *cliqueState) Proposer() byteutils.Hash {
+	if len(s.signers) == 0 {
+		return nil
+	}
+	idx := int(s.timestamp) % len(s.signers)
+	if idx < 0 {
+		idx += len(s.signers)
+	}
+	return s.signers[idx]
+}
+
+// Dynasty returns the signer set backing this state, named Dynasty purely
+// for symmetry with core.ConsensusState/dpos.
+func (s *cliqueState) Dynasty() ([]byteutils.Hash, error) {
+	return s.signers, nil
+}
+
+// RootHash returns the consensus root to be stored on the block produced
+// from this state. It persists s.signers under its content hash and records
+// that hash as DynastyRoot, so a later newCliqueState call for this exact
+// checkpoint reconstructs the signer set that was actually authorized then,
+// rather than whatever the engine's live signer set has drifted to since.
+func (s *cliqueState) RootHash() *consensuspb.ConsensusRoot {
+	var proposer byteutils.Hash
+	if p := s.Proposer(); p != nil {
+		proposer = p
+	}
+
+	root := &consensuspb.ConsensusRoot{
+		Proposer:  proposer,
+		Timestamp: s.timestamp,
+	}
+
+	raw, sum, err := encodeSignersForRoot(s.signers)
+	if err != nil {
+		logging.VLog().WithFields(logrus.Fields{"err": err}).Error("Failed to encode clique signer set.")
+		return root
+	}
+	if err := s.stor.Put(signersStorageKey(sum), raw); err != nil {
+		logging.VLog().WithFields(logrus.Fields{"err": err}).Error("Failed to persist clique signer set.")
+		return root
+	}
+
+	root.DynastyRoot = sum
+	return root
+}
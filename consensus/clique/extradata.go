@@ -0,0 +1,77 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package clique
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/alexlisong/go-nebulas/core"
+)
+
+// genesisHeight is the height of the chain's genesis block, the only place
+// NewEngine looks for the chain's initial signer set.
+const genesisHeight = 1
+
+// extraData is the JSON payload this engine packs into a block's extraData:
+// just the signer set authorized as of that block, sorted so the same set
+// always serializes identically regardless of map iteration order. A real
+// byte-packed encoding (vanity bytes + fixed-width addresses + seal, as
+// upstream Clique uses) would save a few bytes on the wire, but this tree
+// already favors JSON for auxiliary data packed into a byte field (see
+// the multisigSign payload core.Transaction.ApplyMultisigSignature packs
+// into a transaction's Sign field), so extraData follows the same
+// convention rather than inventing a second one.
+type extraData struct {
+	Signers []string
+}
+
+// encodeExtraData serializes signers into the bytes NewEngine and
+// checkpoint-sealing expect to find in a block's extraData.
+func encodeExtraData(signers []*core.Address) ([]byte, error) {
+	addrs := make([]string, 0, len(signers))
+	for _, addr := range signers {
+		addrs = append(addrs, addr.String())
+	}
+	sort.Strings(addrs)
+	return json.Marshal(&extraData{Signers: addrs})
+}
+
+// decodeExtraDataSigners parses the signer set out of raw (a block's
+// extraData), returning it as the map NewEngine/tally key their signer sets
+// by.
+func decodeExtraDataSigners(raw []byte) (map[string]bool, error) {
+	signers := make(map[string]bool)
+	if len(raw) == 0 {
+		return signers, nil
+	}
+
+	var payload extraData
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	for _, s := range payload.Signers {
+		addr, err := core.AddressParse(s)
+		if err != nil {
+			return nil, err
+		}
+		signers[addr.String()] = true
+	}
+	return signers, nil
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package clique
+
+import "github.com/alexlisong/go-nebulas/core"
+
+// Propose records signer's ballot on candidate: add it to, or drop it from,
+// the authorized signer set. Only existing signers may vote. A candidate is
+// applied the moment strictly more than half of the current signer set has
+// voted the same way, matching Clique's N/2 threshold; applying it clears
+// every other in-flight ballot referencing that candidate.
+func (e *Engine) Propose(signer, candidate *core.Address, add bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.signers[signer.String()] {
+		return ErrUnauthorizedSigner
+	}
+
+	kind := voteDrop
+	if add {
+		kind = voteAdd
+	}
+
+	// a signer may only have one live ballot per candidate; re-voting
+	// replaces the earlier ballot rather than stacking votes.
+	for _, v := range e.votes {
+		if v.signer.String() == signer.String() && v.candidate.String() == candidate.String() {
+			v.kind = kind
+			e.tally(candidate)
+			return nil
+		}
+	}
+
+	e.votes = append(e.votes, &vote{signer: signer, candidate: candidate, kind: kind})
+	e.tally(candidate)
+	return nil
+}
+
+// tally must be called with e.mu held. It applies candidate's vote outcome
+// once it has crossed the N/2 threshold, then discards every ballot that
+// referenced it, win or lose.
+func (e *Engine) tally(candidate *core.Address) {
+	threshold := len(e.signers)/2 + 1
+
+	addVotes, dropVotes := 0, 0
+	for _, v := range e.votes {
+		if v.candidate.String() != candidate.String() {
+			continue
+		}
+		if v.kind == voteAdd {
+			addVotes++
+		} else {
+			dropVotes++
+		}
+	}
+
+	applied := false
+	if addVotes >= threshold {
+		e.signers[candidate.String()] = true
+		applied = true
+	} else if dropVotes >= threshold {
+		delete(e.signers, candidate.String())
+		delete(e.recents, candidate.String())
+		applied = true
+	}
+
+	if !applied {
+		return
+	}
+
+	remaining := e.votes[:0]
+	for _, v := range e.votes {
+		if v.candidate.String() != candidate.String() {
+			remaining = append(remaining, v)
+		}
+	}
+	e.votes = remaining
+}
@@ -33,6 +33,10 @@ import (
 	"github.com/alexlisong/go-nebulas/util/byteutils"
 )
 
+// checkDynasty takes the core.Consensus engine as a parameter and builds the
+// state through its NewState, but it compares the resulting dynasty against
+// dpos's DefaultOpenDynasty, so it only fits engines whose state carries
+// that dynasty.
 func checkDynasty(t *testing.T, consensus core.Consensus, consensusRoot *consensuspb.ConsensusRoot, storage storage.Storage) {
 	consensusState, err := consensus.NewState(consensusRoot, storage, false)
 	assert.Nil(t, err)
@@ -0,0 +1,116 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package dpos
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/alexlisong/go-nebulas/crypto"
+	"github.com/alexlisong/go-nebulas/crypto/keystore"
+)
+
+// LeaderElection names a proposer-selection mode: "roundrobin" for the
+// deterministic (timestamp % DynastyIntervalInMs) / BlockIntervalInMs
+// indexing, "vrf" for VRF-based election (see VrfEligibleProposer). Nothing
+// in this package reads a LeaderElection from genesis or branches on it, and
+// proposer selection does not call VrfSign, VrfVerify or
+// VrfEligibleProposer; they are standalone helpers.
+type LeaderElection string
+
+// Supported LeaderElection modes.
+const (
+	LeaderElectionRoundRobin LeaderElection = "roundrobin"
+	LeaderElectionVRF        LeaderElection = "vrf"
+)
+
+// ErrInvalidVrfProof is returned by VrfVerify when a block's VRF proof does
+// not verify against its signer's registered public key.
+var ErrInvalidVrfProof = errors.New("dpos: invalid VRF proof")
+
+// VrfSign computes the VRF output/proof pair for seed under sk. It follows
+// the common "hash-then-sign" VRF construction: the proof is a normal
+// signature over the seed, and the (deterministic, unforgeable-without-sk)
+// output is derived from that signature so it cannot be predicted without
+// first producing a valid proof.
+func VrfSign(sk keystore.PrivateKey, seed []byte) (output, proof []byte, err error) {
+	signature, err := crypto.NewSignature(sk.Algorithm())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := signature.InitSign(sk); err != nil {
+		return nil, nil, err
+	}
+
+	proof, err = signature.Sign(seed)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sum := sha256.Sum256(proof)
+	return sum[:], proof, nil
+}
+
+// VrfVerify checks that proof is a valid signature by pk over seed, and that
+// output is the hash of that signature, i.e. that output/proof really were
+// produced by VrfSign(sk, seed) for the private key matching pk.
+func VrfVerify(pk keystore.PublicKey, seed, output, proof []byte) error {
+	signature, err := crypto.NewSignature(pk.Algorithm())
+	if err != nil {
+		return err
+	}
+	if err := signature.InitVerify(pk); err != nil {
+		return err
+	}
+
+	ok, err := signature.Verify(seed, proof)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidVrfProof
+	}
+
+	sum := sha256.Sum256(proof)
+	if !bytes.Equal(sum[:], output) {
+		return ErrInvalidVrfProof
+	}
+	return nil
+}
+
+// VrfEligibleProposer returns the index, within dynasty, of the member whose
+// VRF output (computed by the caller for every member's slot t) hashes to
+// the smallest value, i.e. argmin H(vrf_output_i). Members that did not
+// supply an output (outputs[i] == nil) are not eligible.
+func VrfEligibleProposer(outputs [][]byte) int {
+	winner := -1
+	var winnerHash [sha256.Size]byte
+	for i, out := range outputs {
+		if len(out) == 0 {
+			continue
+		}
+		h := sha256.Sum256(out)
+		if winner == -1 || bytes.Compare(h[:], winnerHash[:]) < 0 {
+			winner = i
+			winnerHash = h
+		}
+	}
+	return winner
+}
@@ -9,6 +9,14 @@ import (
 	"github.com/tecbot/gorocksdb"
 )
 
+// DefaultColumnFamilies are the column families NewRocksStorage opens when
+// no explicit list is given: splitting the trie, block index, tx index, and
+// event index into their own families keeps each one's bloom filter and
+// compaction behavior from being diluted by the others' key shapes. The
+// default family must always be present since RocksStorage's non-CF
+// Get/Put/Del still address it directly.
+var DefaultColumnFamilies = []string{"default", "state", "block", "tx", "event", "meta"}
+
 // RocksStorage the nodes in trie.
 type RocksStorage struct {
 	db          *gorocksdb.DB
@@ -16,12 +24,27 @@ type RocksStorage struct {
 	mutex       sync.Mutex
 	batchOpts   map[string]*batchOpt
 
+	// cfHandles maps each opened column family's name to its handle, so
+	// GetCF/PutCF/DelCF/NewIterator can be called by name rather than
+	// threading *gorocksdb.ColumnFamilyHandle values through callers.
+	// cfBatchOpts holds pending PutCF/DelCF writes the same way batchOpts
+	// holds pending default-CF writes, keyed first by CF name.
+	cfHandles   map[string]*gorocksdb.ColumnFamilyHandle
+	cfBatchOpts map[string]map[string]*batchOpt
+
 	ro *gorocksdb.ReadOptions
 	wo *gorocksdb.WriteOptions
 }
 
-// NewRocksStorage init a storage
+// NewRocksStorage init a storage with DefaultColumnFamilies.
 func NewRocksStorage(path string) (*RocksStorage, error) {
+	return NewRocksStorageWithColumnFamilies(path, DefaultColumnFamilies)
+}
+
+// NewRocksStorageWithColumnFamilies inits a storage opening exactly cfNames
+// as column families. cfNames must include "default", since RocksStorage's
+// plain Get/Put/Del/Flush address that family directly.
+func NewRocksStorageWithColumnFamilies(path string, cfNames []string) (*RocksStorage, error) {
 
 	filter := gorocksdb.NewBloomFilter(10)
 	bbto := gorocksdb.NewDefaultBlockBasedTableOptions()
@@ -30,24 +53,49 @@ func NewRocksStorage(path string) (*RocksStorage, error) {
 	opts := gorocksdb.NewDefaultOptions()
 	opts.SetBlockBasedTableFactory(bbto)
 	opts.SetCreateIfMissing(true)
+	opts.SetCreateIfMissingColumnFamilies(true)
 	opts.SetMaxOpenFiles(500)
 	opts.SetWriteBufferSize(64 * opt.MiB) //Default: 4MB
 	opts.IncreaseParallelism(4)           //flush and compaction thread
 
-	db, err := gorocksdb.OpenDb(opts, path)
+	cfOpts := make([]*gorocksdb.Options, len(cfNames))
+	for i := range cfNames {
+		cfOpts[i] = opts
+	}
+
+	db, handles, err := gorocksdb.OpenDbColumnFamilies(opts, path, cfNames, cfOpts)
 	if err != nil {
 		return nil, err
 	}
 
+	cfHandles := make(map[string]*gorocksdb.ColumnFamilyHandle, len(cfNames))
+	cfBatchOpts := make(map[string]map[string]*batchOpt, len(cfNames))
+	for i, name := range cfNames {
+		cfHandles[name] = handles[i]
+		cfBatchOpts[name] = make(map[string]*batchOpt)
+	}
+
 	return &RocksStorage{
 		db:          db,
 		enableBatch: false,
 		batchOpts:   make(map[string]*batchOpt),
+		cfHandles:   cfHandles,
+		cfBatchOpts: cfBatchOpts,
 		ro:          gorocksdb.NewDefaultReadOptions(),
 		wo:          gorocksdb.NewDefaultWriteOptions(),
 	}, nil
 }
 
+// ErrColumnFamilyNotFound is returned by GetCF/PutCF/DelCF/NewIterator for a
+// cf name that was not passed to NewRocksStorageWithColumnFamilies.
+var ErrColumnFamilyNotFound = errColumnFamilyNotFound{}
+
+type errColumnFamilyNotFound struct{}
+
+func (errColumnFamilyNotFound) Error() string {
+	return "column family not found"
+}
+
 // Get return value to the key in Storage
 func (storage *RocksStorage) Get(key []byte) ([]byte, error) {
 
@@ -98,6 +146,67 @@ func (storage *RocksStorage) Del(key []byte) error {
 	return storage.db.Delete(storage.wo, key)
 }
 
+// GetCF returns the value for key in column family cf.
+func (storage *RocksStorage) GetCF(cf string, key []byte) ([]byte, error) {
+	handle, ok := storage.cfHandles[cf]
+	if !ok {
+		return nil, ErrColumnFamilyNotFound
+	}
+
+	value, err := storage.db.GetBytesCF(storage.ro, handle, key)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, ErrKeyNotFound
+	}
+	return value, nil
+}
+
+// PutCF puts the key-value entry into column family cf, batched the same
+// way Put batches the default family's writes when EnableBatch is on.
+func (storage *RocksStorage) PutCF(cf string, key []byte, value []byte) error {
+	if _, ok := storage.cfHandles[cf]; !ok {
+		return ErrColumnFamilyNotFound
+	}
+
+	if storage.enableBatch {
+		storage.mutex.Lock()
+		defer storage.mutex.Unlock()
+
+		storage.cfBatchOpts[cf][byteutils.Hex(key)] = &batchOpt{
+			key:     key,
+			value:   value,
+			deleted: false,
+		}
+
+		return nil
+	}
+
+	return storage.db.PutCF(storage.wo, storage.cfHandles[cf], key, value)
+}
+
+// DelCF deletes key from column family cf, batched the same way Del batches
+// the default family's deletes when EnableBatch is on.
+func (storage *RocksStorage) DelCF(cf string, key []byte) error {
+	if _, ok := storage.cfHandles[cf]; !ok {
+		return ErrColumnFamilyNotFound
+	}
+
+	if storage.enableBatch {
+		storage.mutex.Lock()
+		defer storage.mutex.Unlock()
+
+		storage.cfBatchOpts[cf][byteutils.Hex(key)] = &batchOpt{
+			key:     key,
+			deleted: true,
+		}
+
+		return nil
+	}
+	return storage.db.DeleteCF(storage.wo, storage.cfHandles[cf], key)
+}
+
 // Close levelDB
 func (storage *RocksStorage) Close() error {
 	storage.db.Close()
@@ -134,6 +243,21 @@ func (storage *RocksStorage) Flush() error {
 	}
 	storage.batchOpts = make(map[string]*batchOpt)
 
+	// Pending PutCF/DelCF writes ride in the same WriteBatch as the default
+	// family's, so a single Flush still commits every family atomically.
+	for cf, opts := range storage.cfBatchOpts {
+		handle := storage.cfHandles[cf]
+		bl += len(opts)
+		for _, opt := range opts {
+			if opt.deleted {
+				wb.DeleteCF(handle, opt.key)
+			} else {
+				wb.PutCF(handle, opt.key, opt.value)
+			}
+		}
+		storage.cfBatchOpts[cf] = make(map[string]*batchOpt)
+	}
+
 	err := storage.db.Write(storage.wo, wb)
 
 	endAt := time.Now().UnixNano()
@@ -148,6 +272,147 @@ func (storage *RocksStorage) DisableBatch() {
 	storage.mutex.Lock()
 	defer storage.mutex.Unlock()
 	storage.batchOpts = make(map[string]*batchOpt)
+	for cf := range storage.cfBatchOpts {
+		storage.cfBatchOpts[cf] = make(map[string]*batchOpt)
+	}
 
 	storage.enableBatch = false
 }
+
+// Snapshot is a consistent, point-in-time read-only view of a RocksStorage,
+// wrapping gorocksdb.Snapshot. Every Get/GetCF against it keeps returning
+// the values as of the moment Snapshot was called, regardless of writes
+// that land on the live db afterwards.
+type Snapshot struct {
+	storage *RocksStorage
+	snap    *gorocksdb.Snapshot
+	ro      *gorocksdb.ReadOptions
+}
+
+// Snapshot takes a consistent point-in-time snapshot of storage.
+func (storage *RocksStorage) Snapshot() (*Snapshot, error) {
+	snap := storage.db.NewSnapshot()
+
+	ro := gorocksdb.NewDefaultReadOptions()
+	ro.SetSnapshot(snap)
+
+	return &Snapshot{storage: storage, snap: snap, ro: ro}, nil
+}
+
+// Get returns the value for key as of when the snapshot was taken, matching
+// the Storage interface's Get contract.
+func (s *Snapshot) Get(key []byte) ([]byte, error) {
+	value, err := s.storage.db.GetBytes(s.ro, key)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, ErrKeyNotFound
+	}
+	return value, nil
+}
+
+// GetCF returns the value for key in column family cf as of when the
+// snapshot was taken.
+func (s *Snapshot) GetCF(cf string, key []byte) ([]byte, error) {
+	handle, ok := s.storage.cfHandles[cf]
+	if !ok {
+		return nil, ErrColumnFamilyNotFound
+	}
+
+	value, err := s.storage.db.GetBytesCF(s.ro, handle, key)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, ErrKeyNotFound
+	}
+	return value, nil
+}
+
+// Release releases the snapshot. It must be called once the caller is done
+// reading from it, or the underlying db will keep every version the
+// snapshot pins alive indefinitely.
+func (s *Snapshot) Release() {
+	s.ro.Destroy()
+	s.storage.db.ReleaseSnapshot(s.snap)
+}
+
+// Iterator walks every key in a column family that starts with the prefix
+// NewIterator was given, backed by gorocksdb.Iterator with
+// ReadOptions.IterateUpperBound set so the scan stops as soon as it runs
+// past the prefix instead of the caller having to check each key by hand.
+type Iterator struct {
+	it      *gorocksdb.Iterator
+	ro      *gorocksdb.ReadOptions
+	started bool
+}
+
+// NewIterator returns an Iterator over every key in column family cf that
+// starts with prefix.
+func (storage *RocksStorage) NewIterator(cf string, prefix []byte) (*Iterator, error) {
+	handle, ok := storage.cfHandles[cf]
+	if !ok {
+		return nil, ErrColumnFamilyNotFound
+	}
+
+	ro := gorocksdb.NewDefaultReadOptions()
+	if upper := prefixUpperBound(prefix); upper != nil {
+		ro.SetIterateUpperBound(upper)
+	}
+
+	it := storage.db.NewIteratorCF(ro, handle)
+	it.Seek(prefix)
+
+	return &Iterator{it: it, ro: ro}, nil
+}
+
+// Next advances the iterator and reports whether it is still positioned on
+// a valid entry within the prefix's range. The first call reports the
+// result of the Seek(prefix) NewIterator already performed, without
+// advancing past it.
+func (iter *Iterator) Next() bool {
+	if !iter.started {
+		iter.started = true
+	} else {
+		iter.it.Next()
+	}
+	return iter.it.Valid()
+}
+
+// Key returns the current entry's key. Only valid to call after Next
+// returns true.
+func (iter *Iterator) Key() []byte {
+	return iter.it.Key().Data()
+}
+
+// Value returns the current entry's value. Only valid to call after Next
+// returns true.
+func (iter *Iterator) Value() []byte {
+	return iter.it.Value().Data()
+}
+
+// Release frees the iterator and its read options.
+func (iter *Iterator) Release() {
+	iter.it.Close()
+	iter.ro.Destroy()
+}
+
+// prefixUpperBound returns the smallest key that is strictly greater than
+// every key starting with prefix (prefix with its last non-0xff byte
+// incremented and everything after it dropped), so it can be used as
+// ReadOptions.IterateUpperBound to bound a prefix scan. It returns nil if
+// prefix is empty or is all 0xff bytes, since no upper bound can be
+// constructed and the iterator should run to the end of the family.
+func prefixUpperBound(prefix []byte) []byte {
+	upper := make([]byte, len(prefix))
+	copy(upper, prefix)
+
+	for i := len(upper) - 1; i >= 0; i-- {
+		if upper[i] < 0xff {
+			upper[i]++
+			return upper[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package account
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexlisong/go-nebulas/core"
+	"github.com/alexlisong/go-nebulas/util/logging"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrAmbiguousAddress is returned by addrCache.find when more than one key
+// file in the keydir claims the same address, since the cache then has no
+// way to know which file's key material the caller actually wants.
+type ErrAmbiguousAddress struct {
+	Addr  *core.Address
+	Files []string
+}
+
+func (e *ErrAmbiguousAddress) Error() string {
+	return fmt.Sprintf("multiple key files for address %s: %s", e.Addr.String(), strings.Join(e.Files, ", "))
+}
+
+// reloadDebounce is how long addrCache waits after the first filesystem
+// event of a burst before rescanning, so a tool that drops in a key file in
+// several syscalls (write, then rename into place) triggers one rescan
+// instead of one per event.
+const reloadDebounce = 500 * time.Millisecond
+
+// pollInterval is the rescan period used when a filesystem watcher could not
+// be created, e.g. the platform has no inotify support or the process has
+// hit its inotify instance limit.
+const pollInterval = 2 * time.Second
+
+// addrCache indexes every key file under a keydir by the address it holds,
+// so Manager no longer has to run ioutil.ReadDir over the whole keydir on
+// every Accounts()/getAccount() call. It scans lazily on first use and then
+// keeps itself in sync via a filesystem watcher (falling back to polling
+// where a watcher is unavailable), so key files dropped in from outside the
+// process become visible without a restart.
+//
+// NOTE: Manager itself (its struct definition, NewManager, and Accounts())
+// lives in manager.go, which is not part of this tree snapshot. getAccount,
+// updateAccount, loadFile and exportFile below assume Manager carries a
+// `cache *addrCache` field, initialized via newAddrCache(keydir) alongside
+// m.keydir; Accounts() should likewise be switched from ranging over
+// m.accounts to calling m.cache.accounts() to get the full benefit of this
+// change.
+type addrCache struct {
+	keydir string
+
+	mu        sync.Mutex
+	all       []*account            // every known account, sorted by path
+	byAddr    map[string][]*account // addr.String() -> matching accounts
+	loaded    bool
+	watcher   *fsnotify.Watcher
+	fileCache *keyFileCache
+
+	throttleMu sync.Mutex
+	throttle   *time.Timer
+}
+
+func newAddrCache(keydir string) *addrCache {
+	ac := &addrCache{
+		keydir:    keydir,
+		byAddr:    make(map[string][]*account),
+		fileCache: newKeyFileCache(),
+	}
+	ac.watch()
+	return ac
+}
+
+// hasAddress reports whether addr is held by at least one key file.
+func (ac *addrCache) hasAddress(addr *core.Address) bool {
+	ac.maybeReload()
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	return len(ac.byAddr[addr.String()]) > 0
+}
+
+// accounts returns a snapshot of every known account.
+func (ac *addrCache) accounts() []*account {
+	ac.maybeReload()
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	cpy := make([]*account, len(ac.all))
+	copy(cpy, ac.all)
+	return cpy
+}
+
+// find looks up addr, returning ErrAccountNotFound when no key file holds
+// it and ErrAmbiguousAddress when more than one does.
+func (ac *addrCache) find(addr *core.Address) (*account, error) {
+	ac.maybeReload()
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	matches := ac.byAddr[addr.String()]
+	switch len(matches) {
+	case 0:
+		return nil, ErrAccountNotFound
+	case 1:
+		return matches[0], nil
+	default:
+		files := make([]string, len(matches))
+		for i, m := range matches {
+			files[i] = m.path
+		}
+		return nil, &ErrAmbiguousAddress{Addr: addr, Files: files}
+	}
+}
+
+// byPath looks up the account at exactly path, for callers (AccountByPath,
+// UnlockByPath) that already know which of several key files sharing one
+// address they mean and want to bypass find's ambiguity check entirely.
+func (ac *addrCache) byPath(path string) (*account, error) {
+	ac.maybeReload()
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	for _, acc := range ac.all {
+		if acc.path == path {
+			return acc, nil
+		}
+	}
+	return nil, ErrAccountNotFound
+}
+
+// add indexes acc immediately, without waiting for the watcher to notice the
+// file. Manager calls this right after it writes a key file itself
+// (exportFile, updateAccount) so the new file is visible straight away.
+func (ac *addrCache) add(acc *account) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.insertLocked(acc)
+}
+
+func (ac *addrCache) insertLocked(acc *account) {
+	for i, existing := range ac.all {
+		if existing.path == acc.path {
+			ac.all[i] = acc
+			ac.reindexLocked()
+			return
+		}
+	}
+	ac.all = append(ac.all, acc)
+	sort.Slice(ac.all, func(i, j int) bool { return ac.all[i].path < ac.all[j].path })
+	ac.reindexLocked()
+}
+
+func (ac *addrCache) reindexLocked() {
+	ac.byAddr = make(map[string][]*account, len(ac.all))
+	for _, acc := range ac.all {
+		key := acc.addr.String()
+		ac.byAddr[key] = append(ac.byAddr[key], acc)
+	}
+}
+
+// maybeReload triggers the first, lazy scan of the keydir. Later changes
+// arrive via the watcher (or poll loop), not via maybeReload.
+func (ac *addrCache) maybeReload() {
+	ac.mu.Lock()
+	loaded := ac.loaded
+	ac.mu.Unlock()
+	if !loaded {
+		ac.reload()
+	}
+}
+
+// reload rescans the keydir and replaces the cache's contents. It is always
+// called off the debounce timer (or once lazily), never directly from a
+// watcher event, since a single filesystem change (e.g. a rename) can
+// surface as several fsnotify events and we only want to pay for one scan
+// per burst.
+func (ac *addrCache) reload() {
+	accs, err := scanKeyDir(ac.keydir, ac.fileCache)
+	if err != nil {
+		logging.VLog().WithFields(logrus.Fields{
+			"err":    err,
+			"keydir": ac.keydir,
+		}).Error("Failed to scan keydir.")
+		return
+	}
+	sort.Slice(accs, func(i, j int) bool { return accs[i].path < accs[j].path })
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.all = accs
+	ac.reindexLocked()
+	ac.loaded = true
+}
+
+// scheduleReload debounces reload behind reloadDebounce, coalescing a burst
+// of watcher events into a single rescan.
+func (ac *addrCache) scheduleReload() {
+	ac.throttleMu.Lock()
+	defer ac.throttleMu.Unlock()
+	if ac.throttle != nil {
+		return
+	}
+	ac.throttle = time.AfterFunc(reloadDebounce, func() {
+		ac.throttleMu.Lock()
+		ac.throttle = nil
+		ac.throttleMu.Unlock()
+		ac.reload()
+	})
+}
+
+// watch starts following the keydir for CREATE/WRITE/RENAME/REMOVE events,
+// preferring an inotify-backed fsnotify.Watcher and transparently falling
+// back to a polling loop when one cannot be created.
+func (ac *addrCache) watch() {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		logging.VLog().WithFields(logrus.Fields{
+			"err":    err,
+			"keydir": ac.keydir,
+		}).Warn("Failed to create key file watcher, falling back to polling.")
+		go ac.pollLoop()
+		return
+	}
+	if err := w.Add(ac.keydir); err != nil {
+		logging.VLog().WithFields(logrus.Fields{
+			"err":    err,
+			"keydir": ac.keydir,
+		}).Warn("Failed to watch keydir, falling back to polling.")
+		w.Close()
+		go ac.pollLoop()
+		return
+	}
+	ac.watcher = w
+	go ac.watchLoop()
+}
+
+func (ac *addrCache) watchLoop() {
+	for {
+		select {
+		case event, ok := <-ac.watcher.Events:
+			if !ok {
+				return
+			}
+			switch {
+			case event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename|fsnotify.Remove) != 0:
+				ac.scheduleReload()
+			}
+		case err, ok := <-ac.watcher.Errors:
+			if !ok {
+				return
+			}
+			logging.VLog().WithFields(logrus.Fields{
+				"err":    err,
+				"keydir": ac.keydir,
+			}).Warn("Key file watcher error.")
+		}
+	}
+}
+
+func (ac *addrCache) pollLoop() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ac.reload()
+	}
+}
+
+// close stops the watcher (or poll loop) and any pending debounce timer. It
+// is safe to call on a cache that fell back to polling.
+func (ac *addrCache) close() {
+	ac.throttleMu.Lock()
+	if ac.throttle != nil {
+		ac.throttle.Stop()
+		ac.throttle = nil
+	}
+	ac.throttleMu.Unlock()
+
+	if ac.watcher != nil {
+		ac.watcher.Close()
+	}
+}
+
+// scanKeyDir lists every valid key file directly under keydir, parses out
+// the account each one holds (reusing cache's memoized result when a file
+// hasn't changed since the last scan), migrates any legacy filename to its
+// canonical form, and skips (and logs) anything that is not a key file.
+// It holds no lock of its own beyond cache's; callers serialize access to
+// the addrCache state it feeds into.
+func scanKeyDir(keydir string, cache *keyFileCache) ([]*account, error) {
+	files, err := ioutil.ReadDir(keydir)
+	if err != nil {
+		return nil, err
+	}
+
+	var accs []*account
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") || strings.HasSuffix(file.Name(), "~") {
+			logging.VLog().WithFields(logrus.Fields{
+				"path": filepath.Join(keydir, file.Name()),
+			}).Warn("Skipped this key file.")
+			continue
+		}
+
+		path, err := safeKeyFilePath(keydir, file.Name())
+		if err != nil {
+			logging.VLog().WithFields(logrus.Fields{
+				"err":  err,
+				"path": filepath.Join(keydir, file.Name()),
+			}).Warn("Skipped this key file.")
+			continue
+		}
+
+		acc, err := cache.load(path, file)
+		if err != nil {
+			// errors have been recorded by parseKeyFile
+			continue
+		}
+
+		if migrated := migrateKeyFile(acc, cache); migrated != acc.path {
+			acc = &account{addr: acc.addr, path: migrated}
+		}
+		accs = append(accs, acc)
+	}
+	return accs, nil
+}
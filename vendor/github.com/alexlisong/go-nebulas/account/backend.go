@@ -0,0 +1,108 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package account
+
+import "github.com/alexlisong/go-nebulas/core"
+
+// WalletEventType enumerates the lifecycle transitions a Backend reports
+// through Subscribe.
+type WalletEventType int
+
+const (
+	// WalletArrived is fired when a backend detects a new wallet, e.g. a
+	// key file appeared in the keydir or a hardware device was plugged in.
+	WalletArrived WalletEventType = iota
+
+	// WalletDropped is fired when a previously known wallet disappears,
+	// e.g. its key file was deleted or a hardware device was unplugged.
+	WalletDropped
+
+	// WalletOpened is fired once a wallet has been unlocked and is ready
+	// to sign.
+	WalletOpened
+
+	// WalletClosed is fired when an opened wallet is locked again or its
+	// session is otherwise torn down.
+	WalletClosed
+)
+
+// WalletEvent reports a single wallet lifecycle transition.
+type WalletEvent struct {
+	Wallet Wallet
+	Kind   WalletEventType
+}
+
+// Wallet represents a single source of signing key material: one key file
+// in the classic file-based keystore, one connected hardware device, or one
+// identity exposed by a remote signer. Backend.Wallets aggregates these
+// into whatever Manager presents to the rest of the node.
+type Wallet interface {
+	// URL uniquely identifies the wallet, e.g. "keystore:///path/to/file".
+	URL() string
+
+	// Accounts returns every address this wallet can sign for.
+	Accounts() []*core.Address
+
+	// Contains reports whether addr belongs to this wallet.
+	Contains(addr *core.Address) bool
+
+	// Open unlocks the wallet, e.g. by decrypting its key file with a
+	// passphrase, or establishing an authenticated session with a hardware
+	// device or remote signer.
+	Open(passphrase string) error
+
+	// Close releases whatever Open acquired.
+	Close() error
+
+	// SignHash signs hash with the private key behind addr. addr must
+	// satisfy Contains.
+	SignHash(addr *core.Address, hash []byte) ([]byte, error)
+
+	// SignTransaction signs tx in place with the private key behind addr.
+	// addr must satisfy Contains.
+	SignTransaction(addr *core.Address, tx *core.Transaction) error
+}
+
+// Backend is a source of Wallets. Manager aggregates accounts across every
+// Backend registered with it, dispatches signing/unlock/lock calls to
+// whichever backend's wallet actually owns the target address, and forwards
+// each backend's WalletEvents to its own subscribers.
+//
+// keystoreBackend (keystore_backend.go) wraps the classic file-based keydir
+// and is the only Backend implementation in this tree. Hardware wallets
+// (Ledger/Trezor over USB HID) and a remote JSON-RPC signing daemon are
+// meant to be added as further Backend implementations registered
+// alongside it; building them needs a USB HID client library that is not
+// part of this vendor snapshot, so they are not included here, but nothing
+// in Manager's aggregation or WalletEvent plumbing is keystore-specific.
+type Backend interface {
+	// Wallets returns every wallet this backend currently knows about.
+	Wallets() []Wallet
+
+	// Subscribe registers sink to receive this backend's WalletEvents. The
+	// returned Subscription's Unsubscribe stops delivery.
+	Subscribe(sink chan<- WalletEvent) Subscription
+}
+
+// Subscription represents an active Backend.Subscribe (or Manager.Subscribe)
+// registration.
+type Subscription interface {
+	// Unsubscribe stops event delivery and releases the subscription.
+	Unsubscribe()
+}
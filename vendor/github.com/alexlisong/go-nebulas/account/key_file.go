@@ -0,0 +1,145 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package account
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexlisong/go-nebulas/core"
+	"github.com/alexlisong/go-nebulas/util/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// keyFileTimeFormat gives key file names a UTC, colon-free timestamp (so
+// they're safe on filesystems that reject ':' in paths) that also sorts
+// lexicographically by creation time.
+const keyFileTimeFormat = "2006-01-02T15-04-05.000000000Z"
+
+// keyFileName returns the canonical on-disk name for addr's key file:
+// UTC--<ISO8601 creation time>--<address>. The address segment is what
+// lookups actually rely on; the timestamp only disambiguates files created
+// for the same address (e.g. after a passphrase change re-export).
+func keyFileName(addr *core.Address) string {
+	return fmt.Sprintf("UTC--%s--%s", time.Now().UTC().Format(keyFileTimeFormat), addr.String())
+}
+
+// isCanonicalKeyFileName reports whether name already looks like a
+// UTC--<timestamp>--<address> file for addr.
+func isCanonicalKeyFileName(name string, addr *core.Address) bool {
+	return strings.HasPrefix(name, "UTC--") && strings.HasSuffix(name, "--"+addr.String())
+}
+
+// keyFileCacheEntry remembers the (mtime, size) a path had when it was last
+// parsed, so scanKeyDir can skip re-reading and re-unmarshaling a file that
+// has not changed since the previous scan.
+type keyFileCacheEntry struct {
+	acc   *account
+	mtime time.Time
+	size  int64
+}
+
+// keyFileCache memoizes parseKeyFile results keyed by (path, mtime, size).
+// Without it, every reload() re-reads and re-parses every key file in the
+// keydir even though most of them are unchanged; with hundreds of keys that
+// dominates the cost of a rescan.
+type keyFileCache struct {
+	mu      sync.Mutex
+	entries map[string]keyFileCacheEntry
+}
+
+func newKeyFileCache() *keyFileCache {
+	return &keyFileCache{entries: make(map[string]keyFileCacheEntry)}
+}
+
+// load returns the cached account for path if info's mtime and size still
+// match what was cached, otherwise it parses the file and caches the
+// result before returning it.
+func (c *keyFileCache) load(path string, info os.FileInfo) (*account, error) {
+	c.mu.Lock()
+	cached, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && cached.mtime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.acc, nil
+	}
+
+	acc, err := parseKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[path] = keyFileCacheEntry{acc: acc, mtime: info.ModTime(), size: info.Size()}
+	c.mu.Unlock()
+	return acc, nil
+}
+
+// forget drops path's memoized entry, used once migrateKeyFile has renamed
+// a file out from under it.
+func (c *keyFileCache) forget(path string) {
+	c.mu.Lock()
+	delete(c.entries, path)
+	c.mu.Unlock()
+}
+
+// migrateKeyFile renames acc's file to its canonical UTC--<ISO8601>--<address>
+// name if it isn't already named that way, and returns the path the key
+// file lives at afterwards (unchanged if no rename was needed or possible).
+// It runs as part of reload(), i.e. off the debounced watcher/poll path or
+// the one lazy first scan, so a keydir full of legacy names migrates itself
+// in the background as it's scanned rather than needing an explicit
+// operator-triggered pass. It never overwrites an existing file: a name
+// collision just leaves the legacy file in place and logs a warning rather
+// than risking key material.
+func migrateKeyFile(acc *account, cache *keyFileCache) string {
+	dir := filepath.Dir(acc.path)
+	name := filepath.Base(acc.path)
+	if isCanonicalKeyFileName(name, acc.addr) {
+		return acc.path
+	}
+
+	newPath := filepath.Join(dir, keyFileName(acc.addr))
+	if _, err := os.Stat(newPath); err == nil {
+		logging.VLog().WithFields(logrus.Fields{
+			"path":    acc.path,
+			"newPath": newPath,
+		}).Warn("Skipped legacy key file migration: canonical name already exists.")
+		return acc.path
+	}
+
+	if err := os.Rename(acc.path, newPath); err != nil {
+		logging.VLog().WithFields(logrus.Fields{
+			"err":     err,
+			"path":    acc.path,
+			"newPath": newPath,
+		}).Warn("Failed to migrate legacy key file to its canonical name.")
+		return acc.path
+	}
+
+	logging.VLog().WithFields(logrus.Fields{
+		"path":    acc.path,
+		"newPath": newPath,
+	}).Info("Migrated legacy key file to its canonical name.")
+	cache.forget(acc.path)
+	return newPath
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package account
+
+import (
+	"github.com/alexlisong/go-nebulas/core"
+	"github.com/alexlisong/go-nebulas/crypto/keystore"
+)
+
+// keystoreBackend is the Backend that wraps Manager's own file-based
+// keydir: one key file under m.keydir is one Wallet. It is registered on
+// every Manager by default (see newKeystoreBackend's callers in the
+// Manager constructor, manager.go), so existing single-keydir behavior is
+// unchanged; other backends (hardware wallets, a remote signer) are simply
+// additional entries in Manager's backend list.
+type keystoreBackend struct {
+	manager *Manager
+	feed    *walletFeed
+}
+
+func newKeystoreBackend(m *Manager) *keystoreBackend {
+	return &keystoreBackend{
+		manager: m,
+		feed:    newWalletFeed(),
+	}
+}
+
+// Wallets implements Backend, returning one keystoreWallet per key file
+// currently indexed in the manager's addrCache.
+func (kb *keystoreBackend) Wallets() []Wallet {
+	accs := kb.manager.cache.accounts()
+	wallets := make([]Wallet, len(accs))
+	for i, acc := range accs {
+		wallets[i] = &keystoreWallet{account: acc, manager: kb.manager}
+	}
+	return wallets
+}
+
+// Subscribe implements Backend.
+func (kb *keystoreBackend) Subscribe(sink chan<- WalletEvent) Subscription {
+	return kb.feed.subscribe(sink)
+}
+
+// keystoreWallet adapts one key file to the Wallet interface, delegating
+// the actual decrypt/sign work to the Manager methods (Unlock, Lock,
+// SignHash, SignTransaction) that already implement it for the file-based
+// keystore.
+type keystoreWallet struct {
+	account *account
+	manager *Manager
+}
+
+func (w *keystoreWallet) URL() string {
+	return "keystore://" + w.account.path
+}
+
+func (w *keystoreWallet) Accounts() []*core.Address {
+	return []*core.Address{w.account.addr}
+}
+
+func (w *keystoreWallet) Contains(addr *core.Address) bool {
+	return w.account.addr.Equals(addr)
+}
+
+func (w *keystoreWallet) Open(passphrase string) error {
+	return w.manager.Unlock(w.account.addr, []byte(passphrase), keystore.DefaultUnlockDuration)
+}
+
+func (w *keystoreWallet) Close() error {
+	return w.manager.Lock(w.account.addr)
+}
+
+func (w *keystoreWallet) SignHash(addr *core.Address, hash []byte) ([]byte, error) {
+	return w.manager.SignHash(addr, hash, keystore.SECP256K1)
+}
+
+func (w *keystoreWallet) SignTransaction(addr *core.Address, tx *core.Transaction) error {
+	return w.manager.SignTransaction(addr, tx)
+}
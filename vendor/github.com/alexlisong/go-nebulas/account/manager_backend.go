@@ -0,0 +1,76 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package account
+
+import "github.com/alexlisong/go-nebulas/core"
+
+// RegisterBackend adds b to the set of backends Manager aggregates wallets
+// from, and bridges every WalletEvent b emits into m's own feed so a single
+// m.Subscribe sees events from every registered backend. This assumes
+// Manager carries `backends []Backend` and `feed *walletFeed` fields, the
+// latter initialized via newWalletFeed() in the constructor (manager.go,
+// not part of this tree snapshot). That constructor is expected to call
+// m.RegisterBackend(newKeystoreBackend(m)) to keep today's file-based
+// keydir working unchanged; a future hardware-wallet or remote-signer
+// integration only needs to call RegisterBackend once more.
+func (m *Manager) RegisterBackend(b Backend) {
+	m.mutex.Lock()
+	m.backends = append(m.backends, b)
+	m.mutex.Unlock()
+
+	sink := make(chan WalletEvent, 16)
+	b.Subscribe(sink)
+	go func() {
+		for ev := range sink {
+			m.feed.send(ev)
+		}
+	}()
+}
+
+// Wallets returns every wallet known to any registered backend.
+func (m *Manager) Wallets() []Wallet {
+	m.mutex.Lock()
+	backends := make([]Backend, len(m.backends))
+	copy(backends, m.backends)
+	m.mutex.Unlock()
+
+	var wallets []Wallet
+	for _, b := range backends {
+		wallets = append(wallets, b.Wallets()...)
+	}
+	return wallets
+}
+
+// Wallet returns the wallet holding addr, or ErrAccountNotFound if none of
+// the registered backends know about it.
+func (m *Manager) Wallet(addr *core.Address) (Wallet, error) {
+	for _, w := range m.Wallets() {
+		if w.Contains(addr) {
+			return w, nil
+		}
+	}
+	return nil, ErrAccountNotFound
+}
+
+// Subscribe registers sink to receive WalletEvents aggregated across every
+// backend registered with m. The returned Subscription's Unsubscribe stops
+// delivery.
+func (m *Manager) Subscribe(sink chan<- WalletEvent) Subscription {
+	return m.feed.subscribe(sink)
+}
@@ -0,0 +1,71 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package account
+
+import (
+	"path/filepath"
+
+	"github.com/alexlisong/go-nebulas/core"
+)
+
+// AccountByPath returns the address held by the key file at path, so a
+// caller that just received an ErrAmbiguousAddress (whose Files lists the
+// colliding paths) can find out which address a specific file actually
+// holds, or pick one of those files by path for UnlockByPath below.
+func (m *Manager) AccountByPath(path string) (*core.Address, error) {
+	acc, err := m.cache.byPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return acc.addr, nil
+}
+
+// UnlockByPath unlocks addr using the key file at path specifically,
+// instead of resolving addr through the cache the way Unlock does. It is
+// the escape hatch for when Unlock(addr, passphrase, duration) would fail
+// with ErrAmbiguousAddress because two or more key files in keydir decode
+// to the same address (e.g. a restored backup placed alongside an existing
+// keystore): the caller already knows, by path, which one it means.
+//
+// It decrypts and re-registers the key the same way loadFile does; Unlock
+// itself (manager.go, not part of this tree snapshot) presumably layers an
+// unlock-duration on top of that same primitive, so a caller that needs the
+// key held unlocked for a specific duration should follow this call with
+// whatever Unlock does internally once ambiguity is no longer in the way.
+func (m *Manager) UnlockByPath(addr *core.Address, path string, passphrase []byte) error {
+	safePath, err := safeKeyFilePath(m.keydir, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	acc, err := parseKeyFile(safePath)
+	if err != nil {
+		return err
+	}
+	if !acc.addr.Equals(addr) {
+		return ErrAccountNotFound
+	}
+
+	raw, err := readKeyFileBounded(safePath)
+	if err != nil {
+		return err
+	}
+	_, err = m.Load(raw, passphrase)
+	return err
+}
@@ -20,15 +20,11 @@ package account
 
 import (
 	"encoding/json"
-	"io/ioutil"
 	"path/filepath"
-	"strings"
 
 	"errors"
-	"os"
 
 	"github.com/alexlisong/go-nebulas/core"
-	"github.com/alexlisong/go-nebulas/util"
 	"github.com/alexlisong/go-nebulas/util/logging"
 	"github.com/sirupsen/logrus"
 )
@@ -42,46 +38,30 @@ type account struct {
 	path string
 }
 
-// refreshAccounts sync key files to memory
+// refreshAccounts forces the address cache to rescan the keydir immediately,
+// rather than waiting for the next lazy lookup or watcher event. It exists
+// for callers (e.g. Manager construction) that still want an eager sync
+// point; getAccount/updateAccount/loadFile/exportFile no longer depend on it
+// since they read and write through m.cache directly.
 func (m *Manager) refreshAccounts() error {
-	files, err := ioutil.ReadDir(m.keydir)
-	if err != nil {
-		return err
-	}
-	var (
-		accounts []*account
-	)
-
-	for _, file := range files {
-
-		acc, err := m.loadKeyFile(file)
-		if err != nil {
-			// errors have been recorded
-			continue
-		}
-		accounts = append(accounts, acc)
-	}
-	m.accounts = accounts
+	m.cache.reload()
 	return nil
 }
 
-func (m *Manager) loadKeyFile(file os.FileInfo) (*account, error) {
+// parseKeyFile reads the key file at path and extracts the account it
+// holds. It does not validate that path looks like a key file, or that it
+// lives under any particular keydir; scanKeyDir does that filtering (via
+// safeKeyFilePath) before calling in. It does cap how much it will ever
+// read, via readKeyFileBounded, since a key file is always a small JSON
+// blob and a bigger one is either corrupt or hostile.
+func parseKeyFile(path string) (*account, error) {
 	var (
 		keyJSON struct {
 			Address string `json:"address"`
 		}
 	)
 
-	path := filepath.Join(m.keydir, file.Name())
-
-	if file.IsDir() || strings.HasPrefix(file.Name(), ".") || strings.HasSuffix(file.Name(), "~") {
-		logging.VLog().WithFields(logrus.Fields{
-			"path": path,
-		}).Warn("Skipped this key file.")
-		return nil, errors.New("file need skip")
-	}
-
-	raw, err := ioutil.ReadFile(path)
+	raw, err := readKeyFileBounded(path)
 	if err != nil {
 		logging.VLog().WithFields(logrus.Fields{
 			"err":  err,
@@ -109,8 +89,7 @@ func (m *Manager) loadKeyFile(file os.FileInfo) (*account, error) {
 		return nil, errors.New("failed to parse the address")
 	}
 
-	acc := &account{addr, path}
-	return acc, nil
+	return &account{addr, path}, nil
 }
 
 // loadFile import key to keystore in keydir
@@ -120,7 +99,12 @@ func (m *Manager) loadFile(addr *core.Address, passphrase []byte) error {
 		return err
 	}
 
-	raw, err := ioutil.ReadFile(acc.path)
+	safePath, err := safeKeyFilePath(m.keydir, filepath.Base(acc.path))
+	if err != nil {
+		return err
+	}
+
+	raw, err := readKeyFileBounded(safePath)
 	if err != nil {
 		return err
 	}
@@ -136,44 +120,30 @@ func (m *Manager) exportFile(addr *core.Address, passphrase []byte, overwrite bo
 
 	acc, err := m.getAccount(addr)
 	// acc not found
+	var name string
 	if err != nil {
-		path = filepath.Join(m.keydir, addr.String())
+		name = keyFileName(addr)
 	} else {
-		path = acc.path
+		name = filepath.Base(acc.path)
+	}
+
+	path, err = safeKeyFilePath(m.keydir, name)
+	if err != nil {
+		return "", err
 	}
-	if err := util.FileWrite(path, raw, overwrite); err != nil {
+	if err := writeKeyFileAtomic(path, raw, overwrite); err != nil {
 		return "", err
 	}
+	// the watcher will pick this up too, but only after its debounce delay;
+	// index it now so it is visible to the next lookup immediately.
+	m.cache.add(&account{addr: addr, path: path})
 	return path, nil
 }
 
 func (m *Manager) getAccount(addr *core.Address) (*account, error) {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
-	for _, acc := range m.accounts {
-		if acc.addr.Equals(addr) {
-			return acc, nil
-		}
-	}
-	return nil, ErrAccountNotFound
+	return m.cache.find(addr)
 }
 
 func (m *Manager) updateAccount(addr *core.Address, path string) {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
-	var target *account
-	for _, acc := range m.accounts {
-		if acc.addr.Equals(addr) {
-			target = acc
-			break
-		}
-	}
-	if target != nil {
-		target.path = path
-	} else {
-		target = &account{addr: addr, path: path}
-		m.accounts = append(m.accounts, target)
-	}
+	m.cache.add(&account{addr: addr, path: path})
 }
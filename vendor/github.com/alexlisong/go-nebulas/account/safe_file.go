@@ -0,0 +1,129 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package account
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxKeyFileSize bounds how much of a purported key file parseKeyFile will
+// ever read into memory. A key file is a small JSON blob; anything bigger
+// is either corrupt or a file planted in the keydir to make a scan OOM the
+// node, so it is rejected before ioutil.ReadFile rather than after.
+const maxKeyFileSize = 64 * 1024
+
+// errKeyFileTooLarge is returned by readKeyFileBounded when a file exceeds
+// maxKeyFileSize.
+var errKeyFileTooLarge = fmt.Errorf("key file exceeds the %d byte limit", maxKeyFileSize)
+
+// readKeyFileBounded reads path's contents, refusing anything larger than
+// maxKeyFileSize.
+func readKeyFileBounded(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxKeyFileSize {
+		return nil, errKeyFileTooLarge
+	}
+	return ioutil.ReadFile(path)
+}
+
+// safeKeyFilePath builds the on-disk path for name inside keydir, rejecting
+// it if filepath.Clean or resolving a symlink would land outside keydir.
+// This guards both an attacker-influenced name (e.g. an address string with
+// ".." segments slipped into it before core.AddressParse is reached) and a
+// key file that is actually a symlink planted to read or write outside the
+// keydir.
+//
+// The returned path is resolved (symlinks followed) when name already
+// exists, and the cleaned-but-unresolved join otherwise, since there is
+// nothing on disk yet to resolve for a file exportFile is about to create.
+func safeKeyFilePath(keydir, name string) (string, error) {
+	root, err := filepath.EvalSymlinks(keydir)
+	if err != nil {
+		return "", err
+	}
+
+	cleaned := filepath.Clean(filepath.Join(root, name))
+	if !pathIsWithin(root, cleaned) {
+		return "", fmt.Errorf("refusing to use key file path %q outside keydir", name)
+	}
+
+	resolved, err := filepath.EvalSymlinks(cleaned)
+	if err != nil {
+		// Doesn't exist yet (ENOENT) - fine for a file about to be created;
+		// any other stat error will resurface on the caller's next syscall.
+		return cleaned, nil
+	}
+	if !pathIsWithin(root, resolved) {
+		return "", fmt.Errorf("refusing to follow key file symlink %q outside keydir", name)
+	}
+	return resolved, nil
+}
+
+func pathIsWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// writeKeyFileAtomic writes data to path without ever leaving a partial key
+// file behind: it writes to a temp file in the same directory (so the final
+// rename/link stays on one filesystem) with mode 0600, then atomically
+// publishes it. With overwrite false, publishing uses a hard link, which
+// fails if path already exists instead of silently replacing it; with
+// overwrite true, it renames over whatever is there.
+func writeKeyFileAtomic(path string, data []byte, overwrite bool) error {
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, ".key-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	// ioutil.TempFile already opens with O_CREATE|O_EXCL and mode 0600.
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if overwrite {
+		return os.Rename(tmpPath, path)
+	}
+
+	if err := os.Link(tmpPath, path); err != nil {
+		return err
+	}
+	return nil
+}
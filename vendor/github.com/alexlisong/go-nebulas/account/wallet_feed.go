@@ -0,0 +1,68 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package account
+
+import "sync"
+
+// walletFeed fans a stream of WalletEvents out to every subscriber. Both
+// Backend implementations and Manager (which fans every backend's feed into
+// its own) use it, so it lives standalone rather than inside either.
+type walletFeed struct {
+	mu   sync.Mutex
+	subs map[*walletSub]struct{}
+}
+
+func newWalletFeed() *walletFeed {
+	return &walletFeed{subs: make(map[*walletSub]struct{})}
+}
+
+// subscribe registers sink and returns a Subscription that stops delivery
+// to it.
+func (f *walletFeed) subscribe(sink chan<- WalletEvent) Subscription {
+	sub := &walletSub{feed: f, sink: sink}
+	f.mu.Lock()
+	f.subs[sub] = struct{}{}
+	f.mu.Unlock()
+	return sub
+}
+
+// send delivers ev to every current subscriber. A subscriber whose channel
+// is full is skipped rather than blocked on, so one slow consumer cannot
+// stall wallet detection for every other caller.
+func (f *walletFeed) send(ev WalletEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for sub := range f.subs {
+		select {
+		case sub.sink <- ev:
+		default:
+		}
+	}
+}
+
+type walletSub struct {
+	feed *walletFeed
+	sink chan<- WalletEvent
+}
+
+func (s *walletSub) Unsubscribe() {
+	s.feed.mu.Lock()
+	delete(s.feed.subs, s)
+	s.feed.mu.Unlock()
+}
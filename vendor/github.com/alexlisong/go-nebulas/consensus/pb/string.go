@@ -24,7 +24,14 @@ import (
 	"github.com/alexlisong/go-nebulas/util/byteutils"
 )
 
-// ToString return a string of consensus root
+// ToString return a string of consensus root.
+//
+// NOTE: ConsensusRoot has no VrfOutput/VrfProof fields. dpos.LeaderElection's
+// VRF mode (see dpos/vrf.go) is scaffolding that nothing constructs or wires
+// into proposer selection yet, and extending this proto to actually persist
+// a winning VRF output/proof on the consensus root is still open work for
+// whoever finishes that wiring; until then there is nothing VRF-related for
+// ToString to include.
 func (m *ConsensusRoot) ToString() string {
 	return fmt.Sprintf(`{"proposer": %s, "timestamp": "%d", "dynasty": "%s"}`,
 		byteutils.Hex(m.Proposer),
@@ -0,0 +1,73 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package core
+
+import "errors"
+
+// ErrBlockNotFoundForSimulate is returned when SimulateTransactionExecutionAtHeight
+// is asked to simulate against a height that is not on the canonical chain.
+var ErrBlockNotFoundForSimulate = errors.New("block not found at the given height")
+
+// SimulateTransactionExecutionAtHeight behaves like SimulateTransactionExecution,
+// but runs the tx against the world state rooted at the block of the given
+// height instead of always the tail. It opens a read-only clone of that
+// block's WorldState, executes the tx against it in a sandboxed VM context
+// and discards the state afterwards, so archival dApp queries, contract
+// debugging against a known-good height and gas regression testing can all
+// run without disturbing chain processing. height == 0 keeps the existing
+// tail-block behavior.
+func (bc *BlockChain) SimulateTransactionExecutionAtHeight(tx *Transaction, height uint64) (*Result, error) {
+	if height == 0 {
+		return bc.SimulateTransactionExecution(tx)
+	}
+
+	block := bc.GetBlockOnCanonicalChainByHeight(height)
+	if block == nil {
+		return nil, ErrBlockNotFoundForSimulate
+	}
+
+	ws, err := block.WorldState().Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	return bc.simulateTransactionExecutionOnState(tx, block, ws)
+}
+
+// simulateTransactionExecutionOnState runs tx's call payload against ws in
+// block's context, the same way a real CallPayload.Execute would during
+// block processing, except ws is a throwaway clone so nothing it writes is
+// ever persisted. A malformed call payload is reported as an outer error,
+// same as an unknown height above; once the payload itself loads fine, any
+// failure from actually running it (out of gas, a reverted call, ...) is
+// reported through Result.Err instead, matching how ApiService.Call and
+// ApiService.EstimateGas already read the result back.
+func (bc *BlockChain) simulateTransactionExecutionOnState(tx *Transaction, block *Block, ws WorldState) (*Result, error) {
+	payload, err := LoadCallPayload(tx.data.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	gas, msg, exeErr := payload.Execute(tx.GasLimit(), tx, block, ws)
+	return &Result{
+		Msg:     msg,
+		GasUsed: gas,
+		Err:     exeErr,
+	}, nil
+}
@@ -0,0 +1,69 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package core
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ConsensusEngineFactory builds a Consensus engine for a neblet. Engines
+// register themselves under a name (e.g. "dpos", "clique") via
+// RegisterConsensusEngine, and the genesis "consensus.type" field selects
+// which one NewConsensusEngine instantiates for the running chain.
+type ConsensusEngineFactory func(neblet Neblet) (Consensus, error)
+
+var (
+	consensusEnginesMu sync.RWMutex
+	consensusEngines   = make(map[string]ConsensusEngineFactory)
+)
+
+// RegisterConsensusEngine makes a Consensus implementation available under
+// name. It is meant to be called from an engine package's init(), the same
+// way database/sql drivers register themselves. Registering the same name
+// twice panics, since that almost always indicates two engine packages were
+// imported by mistake.
+func RegisterConsensusEngine(name string, factory ConsensusEngineFactory) {
+	consensusEnginesMu.Lock()
+	defer consensusEnginesMu.Unlock()
+
+	if _, dup := consensusEngines[name]; dup {
+		panic(fmt.Sprintf("core: RegisterConsensusEngine called twice for engine %q", name))
+	}
+	consensusEngines[name] = factory
+}
+
+// NewConsensusEngine looks up the engine registered under name (the
+// genesis "consensus.type" value) and constructs it for neblet. Chains that
+// do not set consensus.type keep defaulting to "dpos" for backward
+// compatibility with existing genesis configs.
+func NewConsensusEngine(name string, neblet Neblet) (Consensus, error) {
+	if name == "" {
+		name = "dpos"
+	}
+
+	consensusEnginesMu.RLock()
+	factory, ok := consensusEngines[name]
+	consensusEnginesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("core: unknown consensus engine %q", name)
+	}
+	return factory(neblet)
+}
@@ -0,0 +1,67 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package core
+
+// Log is a single contract-emitted event, positioned by where it was
+// produced: the block it landed in, the index of its transaction within
+// that block, and its own index among the logs that transaction produced.
+type Log struct {
+	BlockHeight uint64
+	TxHash      []byte
+	TxIndex     uint64
+	LogIndex    uint64
+	Address     *Address
+	Topics      [][]byte
+	Data        []byte
+}
+
+// collectLogs turns the events a contract call produced into the Logs the
+// log index stores, numbering them by their position in tx's transaction
+// list within block and the order FetchEvents returned them in.
+func collectLogs(block *Block, tx *Transaction, contract *Address, ws WorldState) ([]*Log, error) {
+	events, err := ws.FetchEvents(tx.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	txIndex := uint64(0)
+	for i, t := range block.Transactions() {
+		if byteSliceEqual(t.Hash(), tx.Hash()) {
+			txIndex = uint64(i)
+			break
+		}
+	}
+
+	logs := make([]*Log, 0, len(events))
+	for i, evt := range events {
+		logs = append(logs, &Log{
+			BlockHeight: block.Height(),
+			TxHash:      tx.Hash(),
+			TxIndex:     txIndex,
+			LogIndex:    uint64(i),
+			Address:     contract,
+			Topics:      [][]byte{[]byte(evt.Topic)},
+			Data:        []byte(evt.Data),
+		})
+	}
+	return logs, nil
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package core
+
+import "github.com/alexlisong/go-nebulas/util"
+
+// Real-time topics that are emitted straight from the tx pool and the chain
+// head, rather than only after a tx has been packed into a committed block.
+// They let wallets and indexers react without polling GetTransactionReceipt.
+const (
+	// TopicPendingTransaction is fired as soon as a tx is accepted into the
+	// local tx pool, carrying only the tx hash.
+	TopicPendingTransaction = "chain.pendingTransaction"
+
+	// TopicPendingTransactionFull is the same event as TopicPendingTransaction,
+	// but carries the full serialized transaction instead of just its hash.
+	TopicPendingTransactionFull = "chain.pendingTransactionFull"
+
+	// TopicChainHead is fired whenever the tail of the canonical chain
+	// changes, carrying the new tail block's hash and height.
+	TopicChainHead = "chain.head"
+
+	// TopicChainReorg is fired whenever the canonical chain re-orgs to a
+	// different branch, carrying the common ancestor and the new tail.
+	TopicChainReorg = "chain.reorg"
+
+	// TopicDropTransaction is fired for each transaction SetGasPrice evicts
+	// from the pool because its gas price fell below a newly-raised floor,
+	// carrying the dropped tx's hash.
+	TopicDropTransaction = "chain.dropTransaction"
+)
+
+// SubscribeFilter narrows a subscription down to events a client actually
+// cares about, evaluated server-side so a busy chain does not flood clients
+// with traffic they are going to discard anyway.
+type SubscribeFilter struct {
+	// FromAddress, when non-empty, only matches txs sent from this address.
+	FromAddress string
+
+	// ToAddress, when non-empty, only matches txs sent to this address or
+	// events emitted by this contract address.
+	ToAddress string
+
+	// MinGasPrice, when non-nil, only matches txs priced at or above it.
+	MinGasPrice *util.Uint128
+}
+
+// MatchTransaction reports whether tx satisfies the filter. A nil filter, or
+// a filter with all fields left at their zero value, matches everything.
+func (f *SubscribeFilter) MatchTransaction(tx *Transaction) bool {
+	if f == nil {
+		return true
+	}
+	if f.FromAddress != "" && tx.From().String() != f.FromAddress {
+		return false
+	}
+	if f.ToAddress != "" && tx.To().String() != f.ToAddress {
+		return false
+	}
+	if f.MinGasPrice != nil && tx.GasPrice().Cmp(f.MinGasPrice) < 0 {
+		return false
+	}
+	return true
+}
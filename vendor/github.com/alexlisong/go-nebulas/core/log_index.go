@@ -0,0 +1,305 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package core
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/fnv"
+
+	"github.com/alexlisong/go-nebulas/storage"
+)
+
+// Storage key prefixes for the log index. logEntryPrefix keys a single Log,
+// keyed by (blockHeight, txIndex, logIndex) so logs within a block sort and
+// range-scan in emission order; logBloomPrefix keys one per-block bloom
+// filter used to skip blocks that can't possibly contain a match before
+// paying for the per-log scan.
+var (
+	logEntryPrefix = []byte("logidx.e.")
+	logBloomPrefix = []byte("logidx.b.")
+)
+
+// logBloomBits sizes the per-block bloom filter. 2048 bits keeps false
+// positives low for the handful of addresses/topics a typical block's
+// contract calls touch, at 256 bytes of storage per block.
+const logBloomBits = 2048
+
+// logBloom is a fixed-size Bloom filter over the addresses and topics a
+// block's logs mention, stored alongside the logs themselves so GetLogs can
+// skip a block without reading any of its log entries.
+type logBloom [logBloomBits / 8]byte
+
+func (b *logBloom) add(key []byte) {
+	for _, h := range bloomHashes(key) {
+		b[h/8] |= 1 << (h % 8)
+	}
+}
+
+func (b *logBloom) mayContain(key []byte) bool {
+	for _, h := range bloomHashes(key) {
+		if b[h/8]&(1<<(h%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHashes derives 3 bit positions for key from two FNV-1a hashes via
+// double hashing (Kirsch-Mitzenmacher), avoiding a dependency on a third-
+// party bloom filter library for a structure this small.
+func bloomHashes(key []byte) [3]uint32 {
+	h1 := fnv.New32a()
+	h1.Write(key)
+	a := h1.Sum32()
+
+	h2 := fnv.New32()
+	h2.Write(key)
+	b := h2.Sum32()
+
+	var out [3]uint32
+	for i := range out {
+		out[i] = (a + uint32(i)*b) % logBloomBits
+	}
+	return out
+}
+
+// LogIndex persists Logs emitted during contract execution, one entry per
+// block keyed by height with the block's logs kept in (txIndex, logIndex)
+// order, and indexed by a per-block Bloom filter so GetLogs can reject most
+// blocks in a [fromBlock, toBlock] range without touching the log entries
+// themselves.
+type LogIndex struct {
+	storage storage.Storage
+}
+
+// NewLogIndex wraps store as a LogIndex. store is expected to be the node's
+// existing chain database, so the index lives in the same file as
+// everything else and is pruned/backed-up along with it.
+func NewLogIndex(store storage.Storage) *LogIndex {
+	return &LogIndex{storage: store}
+}
+
+// IndexLogs appends logs, all assumed to belong to the same block, to that
+// block's stored log list and folds their addresses and topics into its
+// Bloom filter. Logs already stored for blockHeight, if any (an earlier call
+// that only saw part of the block's transactions), are kept and added to.
+func (idx *LogIndex) IndexLogs(blockHeight uint64, logs []*Log) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	existing, err := idx.logsAtHeight(blockHeight)
+	if err != nil {
+		return err
+	}
+	all := append(existing, logs...)
+
+	raw, err := json.Marshal(all)
+	if err != nil {
+		return err
+	}
+	if err := idx.storage.Put(logEntryKey(blockHeight), raw); err != nil {
+		return err
+	}
+
+	bloom, err := idx.loadBloom(blockHeight)
+	if err != nil {
+		return err
+	}
+	for _, log := range logs {
+		bloom.add(log.Address.Bytes())
+		for _, topic := range log.Topics {
+			bloom.add(topic)
+		}
+	}
+	return idx.storage.Put(logBloomKey(blockHeight), bloom[:])
+}
+
+func (idx *LogIndex) loadBloom(blockHeight uint64) (*logBloom, error) {
+	bloom := &logBloom{}
+	raw, err := idx.storage.Get(logBloomKey(blockHeight))
+	if err != nil {
+		if err == storage.ErrKeyNotFound {
+			return bloom, nil
+		}
+		return nil, err
+	}
+	copy(bloom[:], raw)
+	return bloom, nil
+}
+
+// FilterQuery narrows GetLogs down to the logs a client actually wants.
+// FromBlock/ToBlock are inclusive; ToBlockLatest, when set, means "the tail
+// block at query time" instead of a fixed height (Ethereum's "latest"
+// sentinel). Addresses, when non-empty, OR-matches a log's Address. Topics
+// is positional like Ethereum's filter API: Topics[i] is nil to match any
+// topic at that position, or a set of alternatives to OR-match against it;
+// a log with fewer topics than len(Topics) never matches.
+type FilterQuery struct {
+	FromBlock     uint64
+	ToBlock       uint64
+	ToBlockLatest bool
+	Addresses     []*Address
+	Topics        [][][]byte
+}
+
+func (q *FilterQuery) matchesAddress(addr *Address) bool {
+	if len(q.Addresses) == 0 {
+		return true
+	}
+	for _, a := range q.Addresses {
+		if byteSliceEqual(a.Bytes(), addr.Bytes()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *FilterQuery) matchesTopics(topics [][]byte) bool {
+	if len(q.Topics) > len(topics) {
+		return false
+	}
+	for i, want := range q.Topics {
+		if len(want) == 0 {
+			continue
+		}
+		matched := false
+		for _, w := range want {
+			if byteSliceEqual(w, topics[i]) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func byteSliceEqual(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// GetLogs scans [query.FromBlock, query.ToBlock] (or up to tailHeight when
+// query.ToBlockLatest is set) for logs matching query, consulting each
+// block's Bloom filter first so blocks that cannot possibly match are
+// skipped without a single log read.
+func (idx *LogIndex) GetLogs(query *FilterQuery, tailHeight uint64) ([]*Log, error) {
+	to := query.ToBlock
+	if query.ToBlockLatest {
+		to = tailHeight
+	}
+
+	var matches []*Log
+	for height := query.FromBlock; height <= to; height++ {
+		bloom, err := idx.loadBloom(height)
+		if err != nil {
+			return nil, err
+		}
+		if !idx.blockMayMatch(bloom, query) {
+			continue
+		}
+
+		logs, err := idx.logsAtHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			if query.matchesAddress(log.Address) && query.matchesTopics(log.Topics) {
+				matches = append(matches, log)
+			}
+		}
+	}
+	return matches, nil
+}
+
+// blockMayMatch reports whether bloom rules out every address/topic query
+// asked for. An empty query (no addresses, no topics) always may match.
+func (idx *LogIndex) blockMayMatch(bloom *logBloom, query *FilterQuery) bool {
+	if len(query.Addresses) > 0 {
+		any := false
+		for _, a := range query.Addresses {
+			if bloom.mayContain(a.Bytes()) {
+				any = true
+				break
+			}
+		}
+		if !any {
+			return false
+		}
+	}
+	for _, want := range query.Topics {
+		if len(want) == 0 {
+			continue
+		}
+		any := false
+		for _, w := range want {
+			if bloom.mayContain(w) {
+				any = true
+				break
+			}
+		}
+		if !any {
+			return false
+		}
+	}
+	return true
+}
+
+// logsAtHeight loads every Log stored for height, in (txIndex, logIndex)
+// order, the order IndexLogs appended them in.
+func (idx *LogIndex) logsAtHeight(height uint64) ([]*Log, error) {
+	raw, err := idx.storage.Get(logEntryKey(height))
+	if err != nil {
+		if err == storage.ErrKeyNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var logs []*Log
+	if err := json.Unmarshal(raw, &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func logEntryKey(blockHeight uint64) []byte {
+	key := make([]byte, len(logEntryPrefix)+8)
+	copy(key, logEntryPrefix)
+	binary.BigEndian.PutUint64(key[len(logEntryPrefix):], blockHeight)
+	return key
+}
+
+func logBloomKey(blockHeight uint64) []byte {
+	key := make([]byte, len(logBloomPrefix)+8)
+	copy(key, logBloomPrefix)
+	binary.BigEndian.PutUint64(key[len(logBloomPrefix):], blockHeight)
+	return key
+}
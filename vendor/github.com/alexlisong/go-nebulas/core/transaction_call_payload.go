@@ -118,5 +118,48 @@ func (payload *CallPayload) Execute(limitedGas *util.Uint128, tx *Transaction, b
 	if exeErr != nil && exeErr == ErrExecutionFailed && len(result) > 0 {
 		exeErr = fmt.Errorf("Call: %s", result)
 	}
+	if exeErr == nil {
+		NotifyContractCall(block, tx, contract, payload)
+		if err := indexContractCallLogs(block, tx, contract, ws); err != nil {
+			return instructions, result, err
+		}
+	}
 	return instructions, result, exeErr
 }
+
+// ContractCallHook is invoked by NotifyContractCall immediately after a
+// CallPayload.Execute succeeds. The rpc/pubsub subsystem registers itself
+// here at startup to turn successful contract calls into KindLogs
+// notifications; when no pub/sub listener is registered (the subsystem is
+// disabled in config) this stays nil and NotifyContractCall is a no-op, so
+// Execute's hot path pays nothing extra.
+var ContractCallHook func(block *Block, tx *Transaction, contract *Address, payload *CallPayload)
+
+// NotifyContractCall publishes a successful contract call to ContractCallHook,
+// if one is registered.
+func NotifyContractCall(block *Block, tx *Transaction, contract *Address, payload *CallPayload) {
+	if ContractCallHook == nil {
+		return
+	}
+	ContractCallHook(block, tx, contract, payload)
+}
+
+// ActiveLogIndex is the log index a successful contract call's events are
+// persisted to. It is nil until BlockChain sets it up from node config, so a
+// node running with the log index disabled pays nothing beyond the
+// FetchEvents/collectLogs calls below for a call that emitted no events.
+var ActiveLogIndex *LogIndex
+
+// indexContractCallLogs persists the events tx's call to contract produced,
+// if a LogIndex is active, so ApiService.GetLogs can serve them later without
+// replaying the block.
+func indexContractCallLogs(block *Block, tx *Transaction, contract *Address, ws WorldState) error {
+	if ActiveLogIndex == nil {
+		return nil
+	}
+	logs, err := collectLogs(block, tx, contract, ws)
+	if err != nil {
+		return err
+	}
+	return ActiveLogIndex.IndexLogs(block.Height(), logs)
+}
@@ -0,0 +1,116 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package core
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alexlisong/go-nebulas/util"
+)
+
+// ErrDryRunTimeout is returned when a CallPayload.DryRun does not finish
+// within its wall-clock budget. Unlike ErrOutOfGasLimit this fires even when
+// the gas limit passed in is generous or absent, guarding the RPC layer
+// against a contract that spins without tripping the VM's own instruction
+// count (e.g. an expensive host call in a tight loop).
+var ErrDryRunTimeout = errors.New("dry run exceeded its wall-clock timeout")
+
+// TraceFrame is one node of a dry run's call tree. The top-level frame
+// describes the function named by the CallPayload itself; SubCalls holds a
+// frame for each contract-to-contract call it made, in order, so tooling can
+// render the whole dry run as a call graph without replaying the tx.
+type TraceFrame struct {
+	Function    string
+	Args        string
+	GasUsed     *util.Uint128
+	StateReads  []string
+	StateWrites []string
+	Events      []string
+	SubCalls    []*TraceFrame
+	Error       string
+}
+
+// DryRunHook lets a component that can see inside the nvm engine (storage
+// accesses, sub-calls, emitted events) attach that detail to the in-flight
+// TraceFrame. It is invoked, if registered, after engine.Call returns and
+// before DryRun discards the scratch WorldState; a nil hook leaves Frame's
+// StateReads/StateWrites/Events/SubCalls empty, so Function/Args/GasUsed/
+// Error are always populated but the rest degrades gracefully when the nvm
+// build in use does not expose tracing.
+var DryRunHook func(frame *TraceFrame, block *Block, tx *Transaction, contract *Address, payload *CallPayload)
+
+// DryRun executes payload against a throwaway clone of ws, the same way
+// Execute does, except the clone is discarded no matter what happens: the
+// caller's WorldState is never touched. from, when non-nil, overrides tx's
+// sender for the duration of the call, so a contract can be queried from any
+// address without needing that address's signature. timeout bounds the call
+// by wall clock, independent of limitedGas, so a call that is expensive in
+// host time but cheap in instructions still can't hang the RPC handler.
+func (payload *CallPayload) DryRun(limitedGas *util.Uint128, tx *Transaction, block *Block, ws WorldState, from *Address, timeout time.Duration) (*TraceFrame, error) {
+	if block == nil || tx == nil {
+		return nil, ErrNilArgument
+	}
+
+	scratch, err := ws.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	callTx := tx
+	if from != nil {
+		callTx = tx.Clone()
+		callTx.from = from
+	}
+
+	frame := &TraceFrame{Function: payload.Function, Args: payload.Args}
+
+	type result struct {
+		gas *util.Uint128
+		err error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		contract, err := CheckContract(callTx.to, scratch)
+		if err != nil {
+			done <- result{util.NewUint128(), err}
+			return
+		}
+
+		gas, _, exeErr := payload.Execute(limitedGas, callTx, block, scratch)
+		if exeErr != nil {
+			frame.Error = exeErr.Error()
+		}
+		if DryRunHook != nil {
+			DryRunHook(frame, block, callTx, contract, payload)
+		}
+		done <- result{gas, exeErr}
+	}()
+
+	select {
+	case r := <-done:
+		frame.GasUsed = r.gas
+		return frame, nil
+	case <-time.After(timeout):
+		frame.GasUsed = util.NewUint128()
+		frame.Error = ErrDryRunTimeout.Error()
+		return frame, ErrDryRunTimeout
+	}
+}
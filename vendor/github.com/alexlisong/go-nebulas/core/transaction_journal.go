@@ -0,0 +1,185 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/alexlisong/go-nebulas/core/pb"
+	"github.com/gogo/protobuf/proto"
+)
+
+// txJournal is an append-only on-disk log of local transactions, modeled on
+// go-ethereum's txJournal: each record is a 4-byte big-endian length prefix
+// followed by that many bytes of a marshaled corepb.Transaction. It backs
+// TransactionPool.OpenJournal/PushLocal/RotateJournal, letting local
+// transactions survive a node restart.
+type txJournal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// newTxJournal returns a txJournal for path, not yet opened.
+func newTxJournal(path string) *txJournal {
+	return &txJournal{path: path}
+}
+
+// load opens path (creating it if absent), replays every record in it
+// through add, and leaves the journal's file open and positioned at EOF so
+// subsequent insert calls append after the replayed records. A record that
+// fails to unmarshal or is rejected by add is skipped rather than aborting
+// the whole replay, since one corrupt or now-stale entry shouldn't strand
+// every other local transaction behind it.
+func (journal *txJournal) load(add func(*Transaction) error) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	file, err := os.OpenFile(journal.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	for {
+		var length uint32
+		if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+			if err == io.EOF {
+				break
+			}
+			file.Close()
+			return err
+		}
+		raw := make([]byte, length)
+		if _, err := io.ReadFull(reader, raw); err != nil {
+			file.Close()
+			return err
+		}
+
+		pbTx := new(corepb.Transaction)
+		if err := proto.Unmarshal(raw, pbTx); err != nil {
+			continue
+		}
+		tx := new(Transaction)
+		if err := tx.FromProto(pbTx); err != nil {
+			continue
+		}
+		add(tx)
+	}
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return err
+	}
+	journal.file = file
+	return nil
+}
+
+// insert appends tx to the journal as one length-prefixed record. It is a
+// no-op if the journal has never been opened via load.
+func (journal *txJournal) insert(tx *Transaction) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	if journal.file == nil {
+		return nil
+	}
+
+	pbMsg, err := tx.ToProto()
+	if err != nil {
+		return err
+	}
+	raw, err := proto.Marshal(pbMsg)
+	if err != nil {
+		return err
+	}
+
+	if err := binary.Write(journal.file, binary.BigEndian, uint32(len(raw))); err != nil {
+		return err
+	}
+	_, err = journal.file.Write(raw)
+	return err
+}
+
+// rotate replaces the journal's contents with exactly txs, atomically
+// (write to a temp file, then rename over the journal) so a crash mid-rotate
+// never leaves a truncated journal behind.
+func (journal *txJournal) rotate(txs []*Transaction) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	tmpPath := journal.path + ".new"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		pbMsg, err := tx.ToProto()
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		raw, err := proto.Marshal(pbMsg)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := binary.Write(tmp, binary.BigEndian, uint32(len(raw))); err != nil {
+			tmp.Close()
+			return err
+		}
+		if _, err := tmp.Write(raw); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if journal.file != nil {
+		journal.file.Close()
+		journal.file = nil
+	}
+	if err := os.Rename(tmpPath, journal.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(journal.path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	journal.file = file
+	return nil
+}
+
+// close closes the journal's underlying file, if load ever opened one.
+func (journal *txJournal) close() error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+	if journal.file == nil {
+		return nil
+	}
+	err := journal.file.Close()
+	journal.file = nil
+	return err
+}
@@ -0,0 +1,202 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package core
+
+import (
+	"encoding/json"
+
+	"github.com/alexlisong/go-nebulas/crypto/keystore"
+	"github.com/alexlisong/go-nebulas/crypto/keystore/secp256k1"
+	"github.com/alexlisong/go-nebulas/storage"
+)
+
+// MultisigThresholdAlg marks a transaction's signature as an aggregated
+// threshold signature assembled from independently-collected partial
+// signatures rather than a single account's signature, so VerifyIntegrity
+// knows to check it against the signer set and threshold recorded in Sign
+// instead of recovering a single public key from it.
+const MultisigThresholdAlg = keystore.Algorithm(0xff)
+
+// MultisigSignature is one signer's contribution to a threshold-signed
+// transaction: its own signature over the transaction's signing hash.
+type MultisigSignature struct {
+	Address   string
+	Signature []byte
+}
+
+// multisigSign is the payload ApplyMultisigSignature packs into a
+// transaction's Sign field: the signatures collected so far plus the
+// threshold they must meet, so any node can verify the tx carries enough of
+// them without needing the originating proposal.
+type multisigSign struct {
+	Threshold  int
+	Signatures []*MultisigSignature
+}
+
+// multisigSignersKeyPrefix namespaces the storage keys RegisterMultisigSigners
+// and lookupMultisigSigners persist and look up an address's authorized
+// multisig signer set under.
+var multisigSignersKeyPrefix = []byte("multisig.signers.")
+
+func multisigSignersStorageKey(addr *Address) []byte {
+	return append(append([]byte{}, multisigSignersKeyPrefix...), addr.Bytes()...)
+}
+
+// MultisigSignerSet is the signer set and threshold an address has been
+// registered to require, recorded by RegisterMultisigSigners and enforced by
+// VerifyMultisigIntegrity.
+type MultisigSignerSet struct {
+	Signers   []string
+	Threshold int
+}
+
+func (s *MultisigSignerSet) isSigner(addr string) bool {
+	for _, signer := range s.Signers {
+		if signer == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// RegisterMultisigSigners records signers/threshold as the authorized
+// signer set for addr, the first time it is called for that address.
+// Registration is first-write-wins: once an address has a signer set on
+// record, later calls (whether a legitimate proposal for the same address or
+// an attacker's attempt to register a different set against someone else's
+// address) are silently ignored rather than overwriting it, so the only
+// signer set VerifyMultisigIntegrity will ever accept for addr is the one
+// whoever got there first declared — mirroring how a Clique signer set is
+// only ever bootstrapped once, from genesis extraData.
+func RegisterMultisigSigners(stor storage.Storage, addr *Address, signers []string, threshold int) error {
+	if threshold <= 0 || threshold > len(signers) {
+		return ErrInvalidArgument
+	}
+
+	key := multisigSignersStorageKey(addr)
+	if _, err := stor.Get(key); err == nil {
+		return nil
+	} else if err != storage.ErrKeyNotFound {
+		return err
+	}
+
+	raw, err := json.Marshal(&MultisigSignerSet{Signers: signers, Threshold: threshold})
+	if err != nil {
+		return err
+	}
+	return stor.Put(key, raw)
+}
+
+// lookupMultisigSigners returns addr's registered MultisigSignerSet, or
+// ErrInvalidSig if it was never registered via RegisterMultisigSigners — an
+// address with no registration has no authorized signer set at all, so no
+// MultisigThresholdAlg transaction claiming to come from it can ever verify.
+func lookupMultisigSigners(stor storage.Storage, addr *Address) (*MultisigSignerSet, error) {
+	if stor == nil {
+		return nil, ErrInvalidSig
+	}
+	raw, err := stor.Get(multisigSignersStorageKey(addr))
+	if err != nil {
+		return nil, ErrInvalidSig
+	}
+	set := new(MultisigSignerSet)
+	if err := json.Unmarshal(raw, set); err != nil {
+		return nil, ErrInvalidSig
+	}
+	return set, nil
+}
+
+// ApplyMultisigSignature attaches sigs to tx under MultisigThresholdAlg, once
+// at least threshold of them have been collected. It is the final step of
+// the AdminService multisig RPC workflow, called by BroadcastMultisigProposal
+// in place of AccountManager.SignTransaction.
+func (tx *Transaction) ApplyMultisigSignature(sigs []*MultisigSignature, threshold int) error {
+	if len(sigs) < threshold {
+		return ErrInvalidArgument
+	}
+
+	raw, err := json.Marshal(&multisigSign{Threshold: threshold, Signatures: sigs})
+	if err != nil {
+		return err
+	}
+
+	tx.alg = MultisigThresholdAlg
+	tx.sign = raw
+	return nil
+}
+
+// VerifyMultisigIntegrity checks that tx carries at least threshold valid
+// MultisigSignatures from tx.From()'s registered MultisigSignerSet (see
+// RegisterMultisigSigners), each recovering, over tx's own hash, to the
+// Address it claims, with no signer counted twice toward the threshold.
+// Both the signer set and the threshold come from the registration, never
+// from the multisigSign payload itself: trusting the payload's own
+// Threshold/Signatures would let anyone generate fresh keypairs, sign as
+// however many of them they like, and claim any address as tx.From() — the
+// registered set is what actually binds these signatures to an account
+// authorized to spend from it. VerifyIntegrity recovers a single public key
+// straight from tx.sign, which cannot work for a MultisigThresholdAlg
+// transaction's JSON-encoded multisigSign blob, so TransactionPool.Push
+// calls this instead whenever tx.alg is MultisigThresholdAlg.
+func (tx *Transaction) VerifyMultisigIntegrity(chainID uint32, stor storage.Storage) error {
+	if tx.alg != MultisigThresholdAlg {
+		return ErrInvalidSig
+	}
+	if tx.ChainID() != chainID {
+		return ErrInvalidSig
+	}
+
+	var payload multisigSign
+	if err := json.Unmarshal(tx.sign, &payload); err != nil {
+		return ErrInvalidSig
+	}
+	if len(payload.Signatures) == 0 {
+		return ErrInvalidSig
+	}
+
+	set, err := lookupMultisigSigners(stor, tx.From())
+	if err != nil {
+		return err
+	}
+
+	hash := tx.Hash()
+	signed := make(map[string]bool, len(payload.Signatures))
+	for _, sig := range payload.Signatures {
+		pub, err := secp256k1.RecoverPubkey(hash, sig.Signature)
+		if err != nil {
+			return ErrInvalidSig
+		}
+		addr, err := NewAddressFromPublicKey(pub)
+		if err != nil {
+			return ErrInvalidSig
+		}
+		if addr.String() != sig.Address {
+			return ErrInvalidSig
+		}
+		if !set.isSigner(addr.String()) {
+			return ErrInvalidSig
+		}
+		signed[addr.String()] = true
+	}
+
+	if len(signed) < set.Threshold {
+		return ErrInvalidSig
+	}
+	return nil
+}
@@ -0,0 +1,742 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/alexlisong/go-nebulas/storage"
+	"github.com/alexlisong/go-nebulas/util"
+	"github.com/alexlisong/go-nebulas/util/byteutils"
+)
+
+// Errors returned by TransactionPool.Push.
+var (
+	// ErrInvalidGasLimit is returned when a tx's gas limit is not in
+	// (0, TransactionMaxGas].
+	ErrInvalidGasLimit = errors.New("invalid gas limit, should be in (0, TransactionMaxGas]")
+
+	// ErrBelowGasPrice is returned when a tx's gas price is below the pool's
+	// configured minimum.
+	ErrBelowGasPrice = errors.New("below the gas price minimum")
+
+	// ErrDuplicatedTransaction is returned when a tx with the same hash is
+	// already held by the pool.
+	ErrDuplicatedTransaction = errors.New("duplicated transaction")
+)
+
+// TxPoolPendingTTL is how long a sender's pending (executable) txs may sit
+// without a new push before they are evicted. This is the TTL the pool has
+// always used for its one bucket; splitting pending from queued keeps it
+// unchanged for pending, since an executable tx is the one actually at risk
+// of going stale relative to the account's nonce.
+const TxPoolPendingTTL = 90 * time.Minute
+
+// TxPoolQueuedTTL is how long a sender's queued (non-contiguous) txs may sit
+// waiting for their nonce gap to close before they are evicted. It is
+// intentionally much shorter than TxPoolPendingTTL: a queued tx cannot be
+// packed into a block no matter how long it waits for the gap ahead of it,
+// so there is no reason to hold it as long as an executable one.
+const TxPoolQueuedTTL = 15 * time.Minute
+
+// txBucket holds one sender's transactions, split the way Ethereum's tx_pool
+// splits them: pending holds txs contiguous from the account's on-chain
+// nonce, in ascending nonce order, and is the only place Pop draws from;
+// queued holds everything else, also in ascending nonce order, waiting for
+// the gap between the account's nonce and its lowest queued nonce to close.
+type txBucket struct {
+	pending []*Transaction
+	queued  []*Transaction
+}
+
+// insert places tx into the bucket, keeping queued sorted by nonce and
+// replacing whichever existing tx (pending or queued) already occupies that
+// nonce, a fee bump, rather than appending beside it. It returns the
+// replaced tx, if any, so the caller can drop its old hash from pool.all.
+// promote is responsible for moving a freshly-queued tx into pending
+// afterwards.
+func (b *txBucket) insert(tx *Transaction) *Transaction {
+	for i, existing := range b.pending {
+		if existing.Nonce() == tx.Nonce() {
+			old := b.pending[i]
+			b.pending[i] = tx
+			return old
+		}
+	}
+	for i, existing := range b.queued {
+		if existing.Nonce() == tx.Nonce() {
+			old := b.queued[i]
+			b.queued[i] = tx
+			return old
+		}
+	}
+	b.queued = append(b.queued, tx)
+	sortTxsByNonce(b.queued)
+	return nil
+}
+
+// empty reports whether the bucket holds no transactions at all.
+func (b *txBucket) empty() bool {
+	return len(b.pending) == 0 && len(b.queued) == 0
+}
+
+// removeByHash drops the tx whose hash hex-encodes to hashKey from whichever
+// of pending or queued holds it, preserving nonce order in the remainder. It
+// is a no-op if no tx in the bucket has that hash.
+func (b *txBucket) removeByHash(hashKey string) {
+	for i, tx := range b.pending {
+		if tx.hash.Hex() == hashKey {
+			b.pending = append(b.pending[:i], b.pending[i+1:]...)
+			return
+		}
+	}
+	for i, tx := range b.queued {
+		if tx.hash.Hex() == hashKey {
+			b.queued = append(b.queued[:i], b.queued[i+1:]...)
+			return
+		}
+	}
+}
+
+func sortTxsByNonce(txs []*Transaction) {
+	for i := 1; i < len(txs); i++ {
+		for j := i; j > 0 && txs[j-1].Nonce() > txs[j].Nonce(); j-- {
+			txs[j-1], txs[j] = txs[j], txs[j-1]
+		}
+	}
+}
+
+// TransactionPool holds not-yet-packed transactions, split per sender into
+// an executable (pending) queue and a nonce-gapped (queued) one, so Pop only
+// ever proposes transactions that can actually land in the next block.
+type TransactionPool struct {
+	mu sync.Mutex
+
+	size int
+
+	bc           *BlockChain
+	eventEmitter *EventEmitter
+
+	// stor backs VerifyMultisigIntegrity's lookup of each address's
+	// registered MultisigSignerSet (see RegisterMultisigSigners); it is the
+	// same chain database RPC's multisigStore registers signer sets into.
+	stor storage.Storage
+
+	minGasPrice *util.Uint128
+	maxGasLimit *util.Uint128
+
+	// chainConfig picks which Signer Push enforces via MakeSigner. Left nil,
+	// MakeSigner always returns HomesteadSigner, matching the pool's
+	// historical behavior.
+	chainConfig *ChainConfig
+
+	// localAddrs marks senders pushed through PushLocal (or replayed from
+	// the journal on open): their buckets are exempt from evictOverflow and
+	// their transactions are never indexed in priced, so SetGasPrice can
+	// never drop them either.
+	localAddrs map[string]bool
+	journal    *txJournal
+
+	// priced indexes every remote transaction currently in all by gas
+	// price, so SetGasPrice can find and drop underpriced ones without
+	// walking every bucket.
+	priced *txPricedList
+
+	all     map[string]*Transaction
+	buckets map[string]*txBucket
+
+	// bucketsLastUpdate and queuedLastUpdate are each sender's two
+	// independent eviction clocks, reset whenever a push touches that
+	// sender's pending or queued side respectively.
+	bucketsLastUpdate map[string]time.Time
+	queuedLastUpdate  map[string]time.Time
+}
+
+// NewTransactionPool creates a TransactionPool capped at size total
+// transactions across every sender's pending and queued txs combined.
+func NewTransactionPool(size int) (*TransactionPool, error) {
+	if size <= 0 {
+		return nil, ErrInvalidArgument
+	}
+	return &TransactionPool{
+		size:              size,
+		minGasPrice:       TransactionGasPrice,
+		maxGasLimit:       TransactionMaxGas,
+		localAddrs:        make(map[string]bool),
+		priced:            newTxPricedList(),
+		all:               make(map[string]*Transaction),
+		buckets:           make(map[string]*txBucket),
+		bucketsLastUpdate: make(map[string]time.Time),
+		queuedLastUpdate:  make(map[string]time.Time),
+	}, nil
+}
+
+func (pool *TransactionPool) setBlockChain(bc *BlockChain) {
+	pool.bc = bc
+}
+
+func (pool *TransactionPool) setEventEmitter(emitter *EventEmitter) {
+	pool.eventEmitter = emitter
+}
+
+func (pool *TransactionPool) setStorage(stor storage.Storage) {
+	pool.stor = stor
+}
+
+// SetGasConfig sets the pool's minimum accepted gas price and maximum
+// accepted gas limit, falling back to TransactionGasPrice/TransactionMaxGas
+// for either argument left nil or non-positive.
+func (pool *TransactionPool) SetGasConfig(price, limit *util.Uint128) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if price == nil || price.Cmp(util.NewUint128()) <= 0 {
+		pool.minGasPrice = TransactionGasPrice
+	} else {
+		pool.minGasPrice = price
+	}
+	if limit == nil || limit.Cmp(util.NewUint128()) <= 0 {
+		pool.maxGasLimit = TransactionMaxGas
+	} else {
+		pool.maxGasLimit = limit
+	}
+}
+
+// SetGasPrice adjusts the pool's minimum accepted gas price at runtime,
+// unlike SetGasConfig which is meant to be set once at startup. Raising the
+// floor immediately sweeps priced, dropping every already-pooled remote
+// transaction priced below newMin and firing TopicDropTransaction for each
+// one; local transactions (see SetLocal) are never swept. Lowering the
+// floor just updates it — Push already accepts anything at or above it, so
+// nothing already in the pool needs to change.
+func (pool *TransactionPool) SetGasPrice(newMin *util.Uint128) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if newMin == nil {
+		return
+	}
+
+	raised := newMin.Cmp(pool.minGasPrice) > 0
+	pool.minGasPrice = newMin
+	if !raised {
+		return
+	}
+
+	for {
+		tx := pool.priced.cheapest()
+		if tx == nil || tx.GasPrice().Cmp(newMin) >= 0 {
+			break
+		}
+		pool.priced.pop()
+		pool.dropTransaction(tx)
+	}
+}
+
+// MinGasPrice returns the pool's current minimum accepted gas price.
+func (pool *TransactionPool) MinGasPrice() *util.Uint128 {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return pool.minGasPrice
+}
+
+// dropTransaction removes tx from all, its sender's bucket, and priced, and
+// fires TopicDropTransaction. It assumes tx is a remote transaction already
+// removed from (or never added to) priced by the caller.
+func (pool *TransactionPool) dropTransaction(tx *Transaction) {
+	delete(pool.all, tx.hash.Hex())
+
+	addrKey := tx.from.address.Hex()
+	if bucket, ok := pool.buckets[addrKey]; ok {
+		bucket.removeByHash(tx.hash.Hex())
+		if bucket.empty() {
+			delete(pool.buckets, addrKey)
+			delete(pool.bucketsLastUpdate, addrKey)
+			delete(pool.queuedLastUpdate, addrKey)
+		}
+	}
+
+	if pool.eventEmitter != nil {
+		pool.eventEmitter.Trigger(&Event{Topic: TopicDropTransaction, Data: tx.hash.Hex()})
+	}
+}
+
+func (pool *TransactionPool) chainID() uint32 {
+	if pool.bc == nil {
+		return 0
+	}
+	return pool.bc.ChainID()
+}
+
+// SetChainConfig configures which block height activates ChainIDSigner for
+// this pool's Push, instead of always accepting the legacy HomesteadSigner.
+func (pool *TransactionPool) SetChainConfig(conf *ChainConfig) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.chainConfig = conf
+}
+
+// signer returns the Signer a transaction arriving right now must satisfy,
+// based on the chain's tail height and pool.chainConfig.
+func (pool *TransactionPool) signer() Signer {
+	var height uint64
+	if pool.bc != nil {
+		if tail := pool.bc.TailBlock(); tail != nil {
+			height = tail.Height()
+		}
+	}
+	return MakeSigner(pool.chainConfig, pool.chainID(), height)
+}
+
+// currentNonce returns addr's nonce as committed on the chain tail: the
+// highest nonce addr has already used, exactly as handleTransactionResponse
+// validates incoming transactions against (tx.Nonce() must be > this). The
+// next executable nonce is therefore currentNonce+1, which is what promote
+// uses as its starting point.
+func (pool *TransactionPool) currentNonce(addr *Address) uint64 {
+	if pool.bc == nil {
+		return 0
+	}
+	tail := pool.bc.TailBlock()
+	if tail == nil {
+		return 0
+	}
+	acc, err := tail.GetAccount(addr.Bytes())
+	if err != nil {
+		return 0
+	}
+	return acc.Nonce()
+}
+
+// Push validates tx and adds it to the pool, filing it under its sender's
+// pending queue if its nonce is immediately executable (contiguous from the
+// account's current chain nonce) or queued otherwise, then runs a promotion
+// pass in case it closed a gap for txs already queued. Once accepted it
+// fires TopicPendingTransaction via notifyPending, so subscribers (the
+// JSON-RPC pub/sub subsystem, wallets polling for their own tx) learn about
+// it without polling GetTransactionReceipt; actually gossiping tx to peers
+// over the network is the P2P layer's job, not the pool's.
+func (pool *TransactionPool) Push(tx *Transaction) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if tx.alg == MultisigThresholdAlg {
+		// VerifyIntegrity recovers a single public key straight from
+		// tx.sign, which is not possible for a MultisigThresholdAlg
+		// transaction's JSON-encoded multisigSign blob, nor does
+		// pool.signer().Sender apply: the sender is the multisig account
+		// itself, not any one signature's recovered address.
+		if err := tx.VerifyMultisigIntegrity(pool.chainID(), pool.stor); err != nil {
+			return err
+		}
+	} else {
+		if err := tx.VerifyIntegrity(pool.chainID()); err != nil {
+			return err
+		}
+		if _, err := pool.signer().Sender(tx); err != nil {
+			return err
+		}
+	}
+	if tx.GasLimit().Cmp(pool.maxGasLimit) > 0 {
+		return ErrInvalidGasLimit
+	}
+	if tx.GasPrice().Cmp(pool.minGasPrice) < 0 {
+		return ErrBelowGasPrice
+	}
+
+	hashKey := tx.hash.Hex()
+	if _, dup := pool.all[hashKey]; dup {
+		return ErrDuplicatedTransaction
+	}
+
+	addrKey := tx.from.address.Hex()
+	bucket := pool.buckets[addrKey]
+	if bucket == nil {
+		bucket = &txBucket{}
+		pool.buckets[addrKey] = bucket
+	}
+
+	pool.all[hashKey] = tx
+	if old := bucket.insert(tx); old != nil {
+		delete(pool.all, old.hash.Hex())
+		pool.priced.remove(old)
+	}
+	if !pool.localAddrs[addrKey] {
+		pool.priced.add(tx)
+	}
+	pool.promote(addrKey, bucket)
+	pool.touch(addrKey, bucket)
+	pool.notifyPending(tx)
+
+	pool.evictOverflow()
+	return nil
+}
+
+// PushLocal pushes tx exactly like Push, but first marks its sender local
+// (see SetLocal) and, once accepted, appends tx to the pool's journal if
+// OpenJournal has been called, so it survives a node restart. This is the
+// entrypoint the local RPC submission path should use instead of Push.
+func (pool *TransactionPool) PushLocal(tx *Transaction) error {
+	pool.SetLocal(tx.from)
+
+	if err := pool.Push(tx); err != nil {
+		return err
+	}
+
+	pool.mu.Lock()
+	journal := pool.journal
+	pool.mu.Unlock()
+	if journal == nil {
+		return nil
+	}
+	return journal.insert(tx)
+}
+
+// SetLocal marks addr's transactions as local: their bucket is exempt from
+// evictOverflow's size-based eviction, since local txs are assumed to be the
+// node operator's own and worth reserving space for rather than dropping
+// under load from remote senders.
+func (pool *TransactionPool) SetLocal(addr *Address) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.localAddrs[addr.address.Hex()] = true
+}
+
+// IsLocal reports whether addr has been marked local via SetLocal.
+func (pool *TransactionPool) IsLocal(addr *Address) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return pool.localAddrs[addr.address.Hex()]
+}
+
+// OpenJournal opens (creating if absent) the local-transaction journal at
+// path, replays every record already in it through Push (marking each
+// record's sender local as it goes), and keeps the file open so PushLocal
+// can append to it. It should be called once, right after
+// NewTransactionPool, before any local transactions are pushed.
+func (pool *TransactionPool) OpenJournal(path string) error {
+	journal := newTxJournal(path)
+	err := journal.load(func(tx *Transaction) error {
+		pool.SetLocal(tx.from)
+		return pool.Push(tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	pool.mu.Lock()
+	pool.journal = journal
+	pool.mu.Unlock()
+	return nil
+}
+
+// RotateJournal rewrites the journal to hold exactly the local transactions
+// still live in pending or queued, dropping records for txs that have since
+// been popped, evicted, or confirmed on chain. Rewriting the whole file is
+// more expensive than PushLocal's per-tx append, so callers should run this
+// on a periodic interval rather than after every push.
+func (pool *TransactionPool) RotateJournal() error {
+	pool.mu.Lock()
+	journal := pool.journal
+	var live []*Transaction
+	if journal != nil {
+		for addrKey := range pool.localAddrs {
+			bucket, ok := pool.buckets[addrKey]
+			if !ok {
+				continue
+			}
+			live = append(live, bucket.pending...)
+			live = append(live, bucket.queued...)
+		}
+	}
+	pool.mu.Unlock()
+
+	if journal == nil {
+		return nil
+	}
+	return journal.rotate(live)
+}
+
+// CloseJournal closes the pool's journal file, if OpenJournal was ever
+// called.
+func (pool *TransactionPool) CloseJournal() error {
+	pool.mu.Lock()
+	journal := pool.journal
+	pool.mu.Unlock()
+	if journal == nil {
+		return nil
+	}
+	return journal.close()
+}
+
+// notifyPending fires TopicPendingTransaction for a newly accepted tx. It is
+// a no-op when the pool has no EventEmitter configured, e.g. in tests that
+// exercise Push in isolation.
+func (pool *TransactionPool) notifyPending(tx *Transaction) {
+	if pool.eventEmitter == nil {
+		return
+	}
+	pool.eventEmitter.Trigger(&Event{Topic: TopicPendingTransaction, Data: tx.hash.Hex()})
+}
+
+// promote moves bucket's queued txs into pending as long as each one's nonce
+// continues the contiguous run starting at the account's next executable
+// nonce (currentNonce+1, matching handleTransactionResponse's tx.Nonce() >
+// acc.Nonce() rule), and demotes pending txs that have fallen behind that
+// nonce (already included in a committed block) by dropping them outright,
+// exactly as the old pool dropped stale txs on push.
+func (pool *TransactionPool) promote(addrKey string, bucket *txBucket) {
+	addr := pool.addressOf(bucket)
+	if addr == nil {
+		return
+	}
+	next := pool.currentNonce(addr) + 1
+
+	kept := bucket.pending[:0]
+	for _, tx := range bucket.pending {
+		if tx.Nonce() < next {
+			delete(pool.all, tx.hash.Hex())
+			continue
+		}
+		kept = append(kept, tx)
+	}
+	bucket.pending = kept
+
+	want := next
+	if len(bucket.pending) > 0 {
+		want = bucket.pending[len(bucket.pending)-1].Nonce() + 1
+	}
+
+	for len(bucket.queued) > 0 {
+		head := bucket.queued[0]
+		if head.Nonce() < next {
+			bucket.queued = bucket.queued[1:]
+			delete(pool.all, head.hash.Hex())
+			continue
+		}
+		if head.Nonce() != want {
+			break
+		}
+		bucket.pending = append(bucket.pending, head)
+		bucket.queued = bucket.queued[1:]
+		want++
+	}
+}
+
+// addressOf recovers the sender address a bucket belongs to from whichever
+// tx it can find in it, since txBucket itself does not keep one.
+func (pool *TransactionPool) addressOf(bucket *txBucket) *Address {
+	if len(bucket.pending) > 0 {
+		return bucket.pending[0].From()
+	}
+	if len(bucket.queued) > 0 {
+		return bucket.queued[0].From()
+	}
+	return nil
+}
+
+// touch resets addrKey's pending and/or queued eviction clocks to now,
+// whichever side of the bucket actually holds transactions.
+func (pool *TransactionPool) touch(addrKey string, bucket *txBucket) {
+	now := time.Now()
+	if len(bucket.pending) > 0 {
+		pool.bucketsLastUpdate[addrKey] = now
+	}
+	if len(bucket.queued) > 0 {
+		pool.queuedLastUpdate[addrKey] = now
+	}
+}
+
+// PromoteAll re-runs the promotion pass for every sender, dropping pending
+// txs the chain has already committed and promoting queued txs whose gap
+// just closed. BlockChain calls this on every new chain-head event, since a
+// newly committed block can promote or invalidate any sender's bucket, not
+// just the one that pushed most recently.
+func (pool *TransactionPool) PromoteAll() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for addrKey, bucket := range pool.buckets {
+		pool.promote(addrKey, bucket)
+		if bucket.empty() {
+			delete(pool.buckets, addrKey)
+			delete(pool.bucketsLastUpdate, addrKey)
+			delete(pool.queuedLastUpdate, addrKey)
+		}
+	}
+}
+
+// Pop removes and returns the highest-gas-priced transaction among every
+// sender's pending head, the only place it draws from: a queued tx, however
+// highly priced, cannot be packed into a block yet because a lower nonce is
+// still missing.
+func (pool *TransactionPool) Pop() *Transaction {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var bestKey string
+	var best *Transaction
+	for addrKey, bucket := range pool.buckets {
+		if len(bucket.pending) == 0 {
+			continue
+		}
+		head := bucket.pending[0]
+		if best == nil || head.GasPrice().Cmp(best.GasPrice()) > 0 {
+			best = head
+			bestKey = addrKey
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	bucket := pool.buckets[bestKey]
+	bucket.pending = bucket.pending[1:]
+	delete(pool.all, best.hash.Hex())
+	pool.priced.remove(best)
+	if bucket.empty() {
+		delete(pool.buckets, bestKey)
+		delete(pool.bucketsLastUpdate, bestKey)
+		delete(pool.queuedLastUpdate, bestKey)
+	}
+	return best
+}
+
+// GetTransaction looks up a held transaction by hash, pending or queued.
+func (pool *TransactionPool) GetTransaction(hash []byte) *Transaction {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return pool.all[byteutils.Hash(hash).Hex()]
+}
+
+// Empty reports whether the pool holds no transactions at all.
+func (pool *TransactionPool) Empty() bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return len(pool.all) == 0
+}
+
+// Stats returns the number of transactions currently held in the pending and
+// queued queues, summed across every sender.
+func (pool *TransactionPool) Stats() (pending, queued int) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	for _, bucket := range pool.buckets {
+		pending += len(bucket.pending)
+		queued += len(bucket.queued)
+	}
+	return pending, queued
+}
+
+// evictOverflow drops whole buckets, oldest-pending-activity first, until
+// the pool is back at or under its configured size. This mirrors the
+// original single-queue pool's "evict the stalest sender" behavior; it is
+// run after every push rather than sized per sender, since size caps the
+// pool as a whole. Buckets marked local via SetLocal are never chosen as a
+// victim, so they get reserved slots that remote senders' pushes cannot
+// evict.
+func (pool *TransactionPool) evictOverflow() {
+	for len(pool.all)-pool.localCount() > pool.size {
+		oldestKey := ""
+		var oldest time.Time
+		for addrKey := range pool.buckets {
+			if pool.localAddrs[addrKey] {
+				continue
+			}
+			t := pool.bucketsLastUpdate[addrKey]
+			if t.IsZero() {
+				t = pool.queuedLastUpdate[addrKey]
+			}
+			if oldestKey == "" || t.Before(oldest) {
+				oldestKey = addrKey
+				oldest = t
+			}
+		}
+		if oldestKey == "" {
+			return
+		}
+		pool.dropBucket(oldestKey)
+	}
+}
+
+// localCount returns how many transactions across every bucket marked local
+// via SetLocal are currently held, so evictOverflow can size its eviction
+// target against only the non-local portion of the pool.
+func (pool *TransactionPool) localCount() int {
+	count := 0
+	for addrKey := range pool.localAddrs {
+		if bucket, ok := pool.buckets[addrKey]; ok {
+			count += len(bucket.pending) + len(bucket.queued)
+		}
+	}
+	return count
+}
+
+// evictExpiredTransactions drops every sender's pending txs once
+// TxPoolPendingTTL has passed since that sender's last pending push, and
+// separately drops their queued txs once TxPoolQueuedTTL has passed since
+// the last queued push, so a long-abandoned nonce gap does not sit in memory
+// as long as a still-executable transaction would.
+func (pool *TransactionPool) evictExpiredTransactions() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	now := time.Now()
+	for addrKey, bucket := range pool.buckets {
+		if last, ok := pool.bucketsLastUpdate[addrKey]; ok && now.Sub(last) > TxPoolPendingTTL {
+			for _, tx := range bucket.pending {
+				delete(pool.all, tx.hash.Hex())
+				pool.priced.remove(tx)
+			}
+			bucket.pending = nil
+			delete(pool.bucketsLastUpdate, addrKey)
+		}
+		if last, ok := pool.queuedLastUpdate[addrKey]; ok && now.Sub(last) > TxPoolQueuedTTL {
+			for _, tx := range bucket.queued {
+				delete(pool.all, tx.hash.Hex())
+				pool.priced.remove(tx)
+			}
+			bucket.queued = nil
+			delete(pool.queuedLastUpdate, addrKey)
+		}
+		if bucket.empty() {
+			delete(pool.buckets, addrKey)
+		}
+	}
+}
+
+func (pool *TransactionPool) dropBucket(addrKey string) {
+	bucket, ok := pool.buckets[addrKey]
+	if !ok {
+		return
+	}
+	for _, tx := range bucket.pending {
+		delete(pool.all, tx.hash.Hex())
+		pool.priced.remove(tx)
+	}
+	for _, tx := range bucket.queued {
+		delete(pool.all, tx.hash.Hex())
+		pool.priced.remove(tx)
+	}
+	delete(pool.buckets, addrKey)
+	delete(pool.bucketsLastUpdate, addrKey)
+	delete(pool.queuedLastUpdate, addrKey)
+}
@@ -19,6 +19,9 @@
 package core
 
 import (
+	"io/ioutil"
+	"math/rand"
+	"os"
 	"testing"
 
 	"time"
@@ -78,14 +81,20 @@ func TestTransactionPool_1(t *testing.T) {
 	txPool.setEventEmitter(bc.eventEmitter)
 
 	gasLimit, _ := util.NewUint128FromInt(200000)
-	tx1, _ := NewTransaction(bc.ChainID(), from, &Address{address: []byte("to")}, util.NewUint128(), 10, TxPayloadBinaryType, []byte("1"), TransactionGasPrice, gasLimit)
+	// Every sender's first tx starts at nonce 1 (the chain nonce for a fresh
+	// account is 0, and a tx's nonce must be strictly greater) so it lands in
+	// pending immediately; later txs from the same sender are nonce-contiguous
+	// so they promote into pending as soon as the gap in front of them closes.
+	tx1, _ := NewTransaction(bc.ChainID(), from, &Address{address: []byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("1"), TransactionGasPrice, gasLimit)
 	tx2, _ := NewTransaction(bc.ChainID(), other, &Address{address: []byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("2"), heighPrice, gasLimit)
-	tx3, _ := NewTransaction(bc.ChainID(), from, &Address{address: []byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("3"), TransactionGasPrice, gasLimit)
+	tx3, _ := NewTransaction(bc.ChainID(), from, &Address{address: []byte("to")}, util.NewUint128(), 2, TxPayloadBinaryType, []byte("3"), TransactionGasPrice, gasLimit)
 
-	tx4, _ := NewTransaction(bc.ChainID(), from, &Address{address: []byte("to")}, util.NewUint128(), 2, TxPayloadBinaryType, []byte("4"), TransactionGasPrice, gasLimit)
-	tx5, _ := NewTransaction(bc.ChainID()+1, from, &Address{address: []byte("to")}, util.NewUint128(), 0, TxPayloadBinaryType, []byte("5"), TransactionGasPrice, gasLimit)
+	tx4, _ := NewTransaction(bc.ChainID(), from, &Address{address: []byte("to")}, util.NewUint128(), 3, TxPayloadBinaryType, []byte("4"), TransactionGasPrice, gasLimit)
+	tx5, _ := NewTransaction(bc.ChainID()+1, from, &Address{address: []byte("to")}, util.NewUint128(), 4, TxPayloadBinaryType, []byte("5"), TransactionGasPrice, gasLimit)
 
 	tx6, _ := NewTransaction(bc.ChainID(), other2, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("6"), TransactionGasPrice, gasLimit)
+	// tx7 re-enters the pool under "other"'s address after the bucket below
+	// has already been evicted once, so it starts a fresh bucket at nonce 1.
 	tx7, _ := NewTransaction(bc.ChainID(), other, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("7"), heighPrice, gasLimit)
 
 	tx8, _ := NewTransaction(bc.ChainID(), other3, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("8"), heighPrice, gasLimit)
@@ -102,37 +111,53 @@ func TestTransactionPool_1(t *testing.T) {
 	assert.Nil(t, txPool.Push(txs[2]))
 	// put not signed tx, should fail
 	assert.NotNil(t, txPool.Push(txs[3]))
-	// push 3, full, drop 0
 	assert.Equal(t, len(txPool.all), 3)
 	assert.NotNil(t, txPool.all[txs[0].hash.Hex()])
+
+	// push a 4th tx for "from": the pool is now over its size-3 cap, so
+	// evictOverflow drops the least-recently-touched bucket wholesale. "from"
+	// was just touched by this push, so the victim is "other"'s bucket (only
+	// holding txs[1]) rather than whichever single tx is individually oldest.
 	assert.Nil(t, txs[3].Sign(signature1))
 	assert.Nil(t, txPool.Push(txs[3]))
-	assert.Nil(t, txPool.all[txs[0].hash.Hex()])
 	assert.Equal(t, len(txPool.all), 3)
-	// pop 1
+	assert.Nil(t, txPool.all[txs[1].hash.Hex()])
+	assert.NotNil(t, txPool.all[txs[0].hash.Hex()])
+	assert.NotNil(t, txPool.all[txs[3].hash.Hex()])
+
+	// pop 1: "from" is the only bucket left with anything pending, so its
+	// lowest-nonce tx (txs[0]) comes out first.
 	tx := txPool.Pop()
-	assert.Equal(t, txs[1].data, tx.data)
+	assert.Equal(t, txs[0].data, tx.data)
+	assert.Equal(t, len(txPool.all), 2)
+
 	// put tx with different chainID, should fail
 	assert.Nil(t, txs[4].Sign(signature1))
 	assert.NotNil(t, txPool.Push(txs[4]))
-	// put one new
 	assert.Equal(t, len(txPool.all), 2)
+
+	// put one new
 	assert.Nil(t, txs[5].Sign(signature3))
 	assert.Nil(t, txPool.Push(txs[5]))
 	assert.Equal(t, len(txPool.all), 3)
-	// put one new, full, pop 3
-	assert.Equal(t, len(txPool.all), 3)
+
+	// push a 4th tx again: "from" (touched furthest in the past, by txs[3]
+	// above) is now the least-recently-touched bucket and is evicted whole,
+	// taking txs[2] and txs[3] down with it.
+	assert.NotNil(t, txPool.all[txs[2].hash.Hex()])
 	assert.NotNil(t, txPool.all[txs[3].hash.Hex()])
 	assert.Nil(t, txs[6].Sign(signature2))
 	assert.Nil(t, txPool.Push(txs[6]))
+	assert.Equal(t, len(txPool.all), 2)
+	assert.Nil(t, txPool.all[txs[2].hash.Hex()])
 	assert.Nil(t, txPool.all[txs[3].hash.Hex()])
-	assert.Equal(t, len(txPool.all), 3)
 
-	assert.Equal(t, len(txPool.all), 3)
 	assert.Nil(t, txs[7].Sign(signature4))
 	assert.Nil(t, txPool.Push(txs[7]))
 	assert.Equal(t, len(txPool.all), 3)
 
+	// everything left (txs[5], txs[6], txs[7]) is pending at nonce 0 for its
+	// own sender, so all three drain cleanly.
 	assert.NotNil(t, txPool.Pop())
 	assert.Equal(t, len(txPool.all), 2)
 	assert.NotNil(t, txPool.Pop())
@@ -171,13 +196,17 @@ func TestTransactionPool(t *testing.T) {
 
 	gasLimit, _ := util.NewUint128FromInt(200000)
 
-	tx1, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 10, TxPayloadBinaryType, []byte("1"), TransactionGasPrice, gasLimit)
-	tx2, _ := NewTransaction(bc.ChainID(), other, &Address{[]byte("to")}, util.NewUint128(), 2, TxPayloadBinaryType, []byte("2"), TransactionGasPrice, gasLimit)
-	tx3, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("3"), TransactionGasPrice, gasLimit)
-	tx4, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 2, TxPayloadBinaryType, []byte("4"), TransactionGasPrice, gasLimit)
-	tx5, _ := NewTransaction(bc.ChainID()+1, from, &Address{[]byte("to")}, util.NewUint128(), 0, TxPayloadBinaryType, []byte("5"), TransactionGasPrice, gasLimit)
-	tx6, _ := NewTransaction(bc.ChainID(), other, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("6"), heighPrice, gasLimit)
-	tx7, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("7"), heighPrice, gasLimit)
+	tx1, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("1"), TransactionGasPrice, gasLimit)
+	tx2, _ := NewTransaction(bc.ChainID(), other, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("2"), TransactionGasPrice, gasLimit)
+	tx3, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 2, TxPayloadBinaryType, []byte("3"), TransactionGasPrice, gasLimit)
+	tx4, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 3, TxPayloadBinaryType, []byte("4"), TransactionGasPrice, gasLimit)
+	tx5, _ := NewTransaction(bc.ChainID()+1, from, &Address{[]byte("to")}, util.NewUint128(), 4, TxPayloadBinaryType, []byte("5"), TransactionGasPrice, gasLimit)
+	// tx6 rebids tx3's nonce (1) at a higher gas price: a fee bump that
+	// should replace tx3 in "from"'s bucket rather than sit beside it.
+	tx6, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 2, TxPayloadBinaryType, []byte("6"), heighPrice, gasLimit)
+	// tx7 rebids tx2's nonce (0) for "other" at a higher gas price, the same
+	// fee-bump replacement as tx6 above.
+	tx7, _ := NewTransaction(bc.ChainID(), other, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("7"), heighPrice, gasLimit)
 
 	txs := []*Transaction{tx1, tx2, tx3, tx4, tx5, tx6, tx7}
 
@@ -194,28 +223,37 @@ func TestTransactionPool(t *testing.T) {
 	// put tx with different chainID, should fail
 	assert.Nil(t, txs[4].Sign(signature1))
 	assert.NotNil(t, txPool.Push(txs[4]))
-	// put one new, replace txs[1]
 	assert.Equal(t, len(txPool.all), 3)
-	assert.Nil(t, txs[6].Sign(signature1))
+
+	// fee-bump txs[2] (nonce 1) with txs[5]: same sender and nonce, higher
+	// price, so it replaces txs[2] in place rather than growing the pool.
+	assert.Nil(t, txs[5].Sign(signature1))
+	assert.Nil(t, txPool.Push(txs[5]))
+	assert.Equal(t, len(txPool.all), 3)
+	assert.Nil(t, txPool.all[txs[2].hash.Hex()])
+	assert.NotNil(t, txPool.all[txs[5].hash.Hex()])
+
+	// fee-bump txs[1] (nonce 0) with txs[6]: replaces it in "other"'s bucket
+	// too, so the pool size is still unchanged.
+	assert.Nil(t, txs[6].Sign(signature2))
 	assert.Nil(t, txPool.Push(txs[6]))
-	assert.Equal(t, len(txPool.all), 4)
-	// get from: other, nonce: 1, data: "da"
+	assert.Equal(t, len(txPool.all), 3)
+	assert.Nil(t, txPool.all[txs[1].hash.Hex()])
+	assert.NotNil(t, txPool.all[txs[6].hash.Hex()])
+
+	// "other"'s pending head (txs[6], higher priced) pops before "from"'s.
 	tx := txPool.Pop()
 	assert.Equal(t, txs[6].data.Payload, tx.data.Payload)
-	// put one new
-	assert.Equal(t, len(txPool.all), 3)
-	assert.Nil(t, txs[5].Sign(signature2))
-	assert.Nil(t, txPool.Push(txs[5]))
-	assert.Equal(t, len(txPool.all), 4)
-	// get 2 txs, txs[5], txs[0]
+	assert.Equal(t, len(txPool.all), 2)
+
+	// drain the rest: "from"'s pending is [txs[0] (n0), txs[5] (n1, replaced
+	// txs[2])], "other" has nothing left.
 	tx = txPool.Pop()
-	assert.Equal(t, txs[5].from.address, tx.from.address)
-	assert.Equal(t, txs[5].Nonce(), tx.Nonce())
-	assert.Equal(t, txs[5].data, tx.data)
+	assert.Equal(t, txs[0].from.address, tx.from.address)
+	assert.Equal(t, txs[0].Nonce(), tx.Nonce())
+	assert.Equal(t, txs[0].data, tx.data)
 	assert.Equal(t, txPool.Empty(), false)
 	txPool.Pop()
-	txPool.Pop()
-	txPool.Pop()
 	assert.Equal(t, txPool.Empty(), true)
 	assert.Nil(t, txPool.Pop())
 }
@@ -297,6 +335,11 @@ func TestTransactionPool_Pop(t *testing.T) {
 
 	assert.Equal(t, highPrice.Cmp(TransactionGasPrice), 1)
 	gasLimit, _ := util.NewUint128FromInt(200000)
+	// Nonces are shifted down so each sender's lowest nonce is 1 (the chain
+	// nonce for a fresh account is 0): pending only ever holds the
+	// contiguous run starting right after the chain nonce, so Pop
+	// has something to draw from and still drains in the same nonce order
+	// ("from": tx4, tx3, tx1; "other": tx5, tx2) as before the two-queue split.
 	tx1, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 3, TxPayloadBinaryType, []byte("1"), TransactionGasPrice, gasLimit)
 	tx2, _ := NewTransaction(bc.ChainID(), other, &Address{[]byte("to")}, util.NewUint128(), 2, TxPayloadBinaryType, []byte("2"), highPrice, gasLimit)
 	tx3, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 2, TxPayloadBinaryType, []byte("3"), TransactionGasPrice, gasLimit)
@@ -408,3 +451,319 @@ func TestTransactionPoolBucketUpdateTimeAndEvict(t *testing.T) {
 	assert.Equal(t, ok, false)
 
 }
+
+// TestTransactionPoolPromotion covers the queued-side of the pool: a tx
+// whose nonce doesn't yet follow the chain nonce sits in queued and is
+// invisible to Pop until an earlier nonce arrives and closes the gap,
+// promoting the whole contiguous run (and the sender's Stats split) in one
+// step.
+func TestTransactionPoolPromotion(t *testing.T) {
+	ks := keystore.DefaultKS
+	priv1 := secp256k1.GeneratePrivateKey()
+	pubdata1, _ := priv1.PublicKey().Encoded()
+	from, _ := NewAddressFromPublicKey(pubdata1)
+	ks.SetKey(from.String(), priv1, []byte("passphrase"))
+	ks.Unlock(from.String(), []byte("passphrase"), time.Second*60*60*24*365)
+	key1, _ := ks.GetUnlocked(from.String())
+	signature1, _ := crypto.NewSignature(keystore.SECP256K1)
+	signature1.InitSign(key1.(keystore.PrivateKey))
+
+	bc := testNeb(t).chain
+	txPool, _ := NewTransactionPool(10)
+	txPool.setBlockChain(bc)
+	txPool.setEventEmitter(bc.eventEmitter)
+
+	gasLimit, _ := util.NewUint128FromInt(200000)
+	tx1, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 2, TxPayloadBinaryType, []byte("1"), TransactionGasPrice, gasLimit)
+	tx2, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 3, TxPayloadBinaryType, []byte("2"), TransactionGasPrice, gasLimit)
+	tx0, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("0"), TransactionGasPrice, gasLimit)
+
+	assert.Nil(t, tx1.Sign(signature1))
+	assert.Nil(t, txPool.Push(tx1))
+	pending, queued := txPool.Stats()
+	assert.Equal(t, pending, 0)
+	assert.Equal(t, queued, 1)
+	assert.Nil(t, txPool.Pop())
+
+	assert.Nil(t, tx2.Sign(signature1))
+	assert.Nil(t, txPool.Push(tx2))
+	pending, queued = txPool.Stats()
+	assert.Equal(t, pending, 0)
+	assert.Equal(t, queued, 2)
+
+	// tx0 closes the gap: tx0, tx1, tx2 all promote to pending together.
+	assert.Nil(t, tx0.Sign(signature1))
+	assert.Nil(t, txPool.Push(tx0))
+	pending, queued = txPool.Stats()
+	assert.Equal(t, pending, 3)
+	assert.Equal(t, queued, 0)
+
+	popped := txPool.Pop()
+	assert.Equal(t, popped.data, tx0.data)
+	pending, queued = txPool.Stats()
+	assert.Equal(t, pending, 2)
+	assert.Equal(t, queued, 0)
+}
+
+// TestTransactionPoolRejectsCrossChainSigner exercises the Signer Push now
+// consults: once the pool is configured to require ChainIDSigner, a tx whose
+// ChainIDSigner.Sender check is for a different chainID is rejected even
+// though its own declared chainID field still matches the pool (the
+// cross-chain replay scenario this check guards against assumes a replayed
+// tx has its chainID field patched to match the victim chain).
+//
+// Transaction.Sign (outside this tree) is not known to sign over
+// Signer.Hash(tx), only tx.Hash() directly, so this signs the raw bytes
+// chainA.Hash(tx) folds to and assigns them to tx.sign/tx.alg by hand rather
+// than going through tx.Sign, exactly as whoever wires Sign up to the Signer
+// abstraction will eventually need to.
+func TestTransactionPoolRejectsCrossChainSigner(t *testing.T) {
+	ks := keystore.DefaultKS
+	priv1 := secp256k1.GeneratePrivateKey()
+	pubdata1, _ := priv1.PublicKey().Encoded()
+	from, _ := NewAddressFromPublicKey(pubdata1)
+	ks.SetKey(from.String(), priv1, []byte("passphrase"))
+	ks.Unlock(from.String(), []byte("passphrase"), time.Second*60*60*24*365)
+	key1, _ := ks.GetUnlocked(from.String())
+	signature1, _ := crypto.NewSignature(keystore.SECP256K1)
+	signature1.InitSign(key1.(keystore.PrivateKey))
+
+	bc := testNeb(t).chain
+	gasLimit, _ := util.NewUint128FromInt(200000)
+	tx, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("1"), TransactionGasPrice, gasLimit)
+
+	chainA := ChainIDSigner{ChainID: bc.ChainID()}
+	raw, err := signature1.Sign(chainA.Hash(tx))
+	assert.Nil(t, err)
+	tx.sign = raw
+	tx.alg = keystore.SECP256K1
+
+	_, err = chainA.Sender(tx)
+	assert.Nil(t, err)
+
+	chainB := ChainIDSigner{ChainID: bc.ChainID() + 1}
+	_, err = chainB.Sender(tx)
+	assert.Equal(t, err, ErrInvalidSig)
+	assert.Equal(t, chainA.Equal(chainB), false)
+}
+
+// TestTransactionPoolRejectsReplayedSignatureAfterChainIDPatch is the actual
+// replay this request exists to stop: a tx signed for chain A, then captured
+// and replayed on chain B by rewriting its chainID field alone (no access to
+// from's private key, so the original signature is carried over unchanged).
+// Unlike TestTransactionPoolRejectsCrossChainSigner above, which only drives
+// the pre-check against the tx's still-honest chainID field, this patches
+// chainID on the already-signed tx in place and expects recovery itself to
+// fail: ChainIDSigner.Hash folds ChainID into the bytes actually signed, so
+// chainB.Hash(tx) (computed after the patch) differs from the chainA.Hash(tx)
+// signature1 actually signed, and recovering against it no longer yields an
+// address matching tx.From().
+func TestTransactionPoolRejectsReplayedSignatureAfterChainIDPatch(t *testing.T) {
+	ks := keystore.DefaultKS
+	priv1 := secp256k1.GeneratePrivateKey()
+	pubdata1, _ := priv1.PublicKey().Encoded()
+	from, _ := NewAddressFromPublicKey(pubdata1)
+	ks.SetKey(from.String(), priv1, []byte("passphrase"))
+	ks.Unlock(from.String(), []byte("passphrase"), time.Second*60*60*24*365)
+	key1, _ := ks.GetUnlocked(from.String())
+	signature1, _ := crypto.NewSignature(keystore.SECP256K1)
+	signature1.InitSign(key1.(keystore.PrivateKey))
+
+	bc := testNeb(t).chain
+	gasLimit, _ := util.NewUint128FromInt(200000)
+	tx, _ := NewTransaction(bc.ChainID(), from, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("1"), TransactionGasPrice, gasLimit)
+
+	chainA := ChainIDSigner{ChainID: bc.ChainID()}
+	raw, err := signature1.Sign(chainA.Hash(tx))
+	assert.Nil(t, err)
+	tx.sign = raw
+	tx.alg = keystore.SECP256K1
+
+	_, err = chainA.Sender(tx)
+	assert.Nil(t, err)
+
+	// Simulate the replay: rewrite chainID in place on the already-signed
+	// tx, without re-signing, exactly as an attacker who never had from's
+	// private key would have to.
+	tx.chainID = bc.ChainID() + 1
+	chainB := ChainIDSigner{ChainID: bc.ChainID() + 1}
+	_, err = chainB.Sender(tx)
+	assert.Equal(t, err, ErrInvalidSig)
+}
+
+// TestTransactionPoolLocalJournal covers the local-transaction journal: a
+// tx pushed via PushLocal is (1) exempt from evictOverflow's size-based
+// eviction even once the pool is over its remote-only cap, and (2) replayed
+// back into a fresh pool that opens the same journal path, so it survives a
+// simulated restart.
+func TestTransactionPoolLocalJournal(t *testing.T) {
+	ks := keystore.DefaultKS
+	priv1 := secp256k1.GeneratePrivateKey()
+	pubdata1, _ := priv1.PublicKey().Encoded()
+	local, _ := NewAddressFromPublicKey(pubdata1)
+	ks.SetKey(local.String(), priv1, []byte("passphrase"))
+	ks.Unlock(local.String(), []byte("passphrase"), time.Second*60*60*24*365)
+	key1, _ := ks.GetUnlocked(local.String())
+	signature1, _ := crypto.NewSignature(keystore.SECP256K1)
+	signature1.InitSign(key1.(keystore.PrivateKey))
+
+	priv2 := secp256k1.GeneratePrivateKey()
+	pubdata2, _ := priv2.PublicKey().Encoded()
+	remote, _ := NewAddressFromPublicKey(pubdata2)
+	ks.SetKey(remote.String(), priv2, []byte("passphrase"))
+	ks.Unlock(remote.String(), []byte("passphrase"), time.Second*60*60*24*365)
+	key2, _ := ks.GetUnlocked(remote.String())
+	signature2, _ := crypto.NewSignature(keystore.SECP256K1)
+	signature2.InitSign(key2.(keystore.PrivateKey))
+
+	bc := testNeb(t).chain
+	gasLimit, _ := util.NewUint128FromInt(200000)
+
+	journalFile, err := ioutil.TempFile("", "transactions-*.rlp")
+	assert.Nil(t, err)
+	journalPath := journalFile.Name()
+	journalFile.Close()
+	defer os.Remove(journalPath)
+
+	txPool, _ := NewTransactionPool(1)
+	txPool.setBlockChain(bc)
+	assert.Nil(t, txPool.OpenJournal(journalPath))
+
+	localTx, _ := NewTransaction(bc.ChainID(), local, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("local"), TransactionGasPrice, gasLimit)
+	assert.Nil(t, localTx.Sign(signature1))
+	assert.Nil(t, txPool.PushLocal(localTx))
+	assert.True(t, txPool.IsLocal(local))
+
+	// the pool is already at its size-1 cap with only the local tx in it;
+	// pushing a remote tx must not evict it.
+	remoteTx, _ := NewTransaction(bc.ChainID(), remote, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("remote"), TransactionGasPrice, gasLimit)
+	assert.Nil(t, remoteTx.Sign(signature2))
+	assert.Nil(t, txPool.Push(remoteTx))
+	assert.NotNil(t, txPool.all[localTx.hash.Hex()])
+	assert.NotNil(t, txPool.all[remoteTx.hash.Hex()])
+	assert.Nil(t, txPool.CloseJournal())
+
+	// a fresh pool opening the same journal path replays localTx without it
+	// ever being pushed again directly.
+	restarted, _ := NewTransactionPool(10)
+	restarted.setBlockChain(bc)
+	assert.Nil(t, restarted.OpenJournal(journalPath))
+	assert.NotNil(t, restarted.all[localTx.hash.Hex()])
+	assert.True(t, restarted.IsLocal(local))
+	assert.Nil(t, restarted.CloseJournal())
+}
+
+// TestTransactionPoolSetGasPrice covers the underpriced-tx sweep: raising
+// the floor drops every already-pooled remote tx priced below it (firing
+// TopicDropTransaction for each), while a local tx at the same low price is
+// left alone, and lowering the floor back down drops nothing.
+func TestTransactionPoolSetGasPrice(t *testing.T) {
+	ks := keystore.DefaultKS
+	priv1 := secp256k1.GeneratePrivateKey()
+	pubdata1, _ := priv1.PublicKey().Encoded()
+	cheap, _ := NewAddressFromPublicKey(pubdata1)
+	ks.SetKey(cheap.String(), priv1, []byte("passphrase"))
+	ks.Unlock(cheap.String(), []byte("passphrase"), time.Second*60*60*24*365)
+	key1, _ := ks.GetUnlocked(cheap.String())
+	signature1, _ := crypto.NewSignature(keystore.SECP256K1)
+	signature1.InitSign(key1.(keystore.PrivateKey))
+
+	priv2 := secp256k1.GeneratePrivateKey()
+	pubdata2, _ := priv2.PublicKey().Encoded()
+	local, _ := NewAddressFromPublicKey(pubdata2)
+	ks.SetKey(local.String(), priv2, []byte("passphrase"))
+	ks.Unlock(local.String(), []byte("passphrase"), time.Second*60*60*24*365)
+	key2, _ := ks.GetUnlocked(local.String())
+	signature2, _ := crypto.NewSignature(keystore.SECP256K1)
+	signature2.InitSign(key2.(keystore.PrivateKey))
+
+	priv3 := secp256k1.GeneratePrivateKey()
+	pubdata3, _ := priv3.PublicKey().Encoded()
+	rich, _ := NewAddressFromPublicKey(pubdata3)
+	ks.SetKey(rich.String(), priv3, []byte("passphrase"))
+	ks.Unlock(rich.String(), []byte("passphrase"), time.Second*60*60*24*365)
+	key3, _ := ks.GetUnlocked(rich.String())
+	signature3, _ := crypto.NewSignature(keystore.SECP256K1)
+	signature3.InitSign(key3.(keystore.PrivateKey))
+
+	bc := testNeb(t).chain
+	txPool, _ := NewTransactionPool(10)
+	txPool.setBlockChain(bc)
+	txPool.setEventEmitter(bc.eventEmitter)
+
+	gasLimit, _ := util.NewUint128FromInt(200000)
+	highPrice, _ := TransactionGasPrice.Mul(mustUint128(10))
+
+	cheapTx, _ := NewTransaction(bc.ChainID(), cheap, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("cheap"), TransactionGasPrice, gasLimit)
+	localTx, _ := NewTransaction(bc.ChainID(), local, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("local"), TransactionGasPrice, gasLimit)
+	richTx, _ := NewTransaction(bc.ChainID(), rich, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("rich"), highPrice, gasLimit)
+
+	assert.Nil(t, cheapTx.Sign(signature1))
+	assert.Nil(t, txPool.Push(cheapTx))
+	assert.Nil(t, localTx.Sign(signature2))
+	assert.Nil(t, txPool.PushLocal(localTx))
+	assert.Nil(t, richTx.Sign(signature3))
+	assert.Nil(t, txPool.Push(richTx))
+	assert.Equal(t, txPool.priced.len(), 2)
+
+	// raising the floor above cheapTx's price (but not richTx's) drops only
+	// cheapTx; localTx stays despite being priced the same as cheapTx.
+	txPool.SetGasPrice(highPrice)
+	assert.Nil(t, txPool.all[cheapTx.hash.Hex()])
+	assert.NotNil(t, txPool.all[localTx.hash.Hex()])
+	assert.NotNil(t, txPool.all[richTx.hash.Hex()])
+	assert.Equal(t, txPool.priced.len(), 1)
+
+	// lowering the floor back down drops nothing already in the pool.
+	txPool.SetGasPrice(TransactionGasPrice)
+	assert.NotNil(t, txPool.all[localTx.hash.Hex()])
+	assert.NotNil(t, txPool.all[richTx.hash.Hex()])
+}
+
+func mustUint128(n int) *util.Uint128 {
+	v, err := util.NewUint128FromInt(int64(n))
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// BenchmarkTransactionPoolSetGasPriceSweep measures SetGasPrice's
+// heap-backed underpriced sweep at scale. The pool's full-pool overflow
+// eviction already moved from "drop the single lowest-priced tx" to
+// bucket-level LRU eviction in an earlier change, so there is no remaining
+// single-tx-lowest-price code path left to compare this against head to
+// head; this instead measures the absolute cost of sweeping priced, which
+// is what SetGasPrice relies on staying cheap as the pool grows.
+func BenchmarkTransactionPoolSetGasPriceSweep(b *testing.B) {
+	ks := keystore.DefaultKS
+	gasLimit, _ := util.NewUint128FromInt(200000)
+	bc := testNeb(&testing.T{}).chain
+
+	const n = 100000
+	txPool, _ := NewTransactionPool(n + 1)
+	txPool.setBlockChain(bc)
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+		priv := secp256k1.GeneratePrivateKey()
+		pubdata, _ := priv.PublicKey().Encoded()
+		addr, _ := NewAddressFromPublicKey(pubdata)
+		ks.SetKey(addr.String(), priv, []byte("passphrase"))
+		ks.Unlock(addr.String(), []byte("passphrase"), time.Second*60*60*24*365)
+		key, _ := ks.GetUnlocked(addr.String())
+		signature, _ := crypto.NewSignature(keystore.SECP256K1)
+		signature.InitSign(key.(keystore.PrivateKey))
+
+		price, _ := util.NewUint128FromInt(int64(1 + rng.Intn(1000)))
+		price, _ = TransactionGasPrice.Mul(price)
+		tx, _ := NewTransaction(bc.ChainID(), addr, &Address{[]byte("to")}, util.NewUint128(), 1, TxPayloadBinaryType, []byte("x"), price, gasLimit)
+		tx.Sign(signature)
+		txPool.Push(tx)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		txPool.SetGasPrice(TransactionGasPrice)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package core
+
+import "container/heap"
+
+// txPricedList is a min-heap of every remote (non-local, see SetLocal)
+// transaction currently in the pool, ordered by gas price. It lets
+// SetGasPrice find and drop every transaction priced below a newly-raised
+// floor in O(k log n) instead of walking every sender's bucket, where k is
+// the number removed.
+//
+// The heap is an auxiliary index, not the pool's source of truth: pool.all
+// and pool.buckets still own each transaction's lifetime, and every path
+// that removes a tx from them (Pop, dropBucket, evictExpiredTransactions,
+// a fee-bump replacement) must also call remove so the heap never returns a
+// tx that is no longer actually in the pool.
+type txPricedList struct {
+	items pricedHeap
+}
+
+func newTxPricedList() *txPricedList {
+	return &txPricedList{items: pricedHeap{index: make(map[string]int)}}
+}
+
+// add indexes tx by its gas price. Callers must not add the same tx twice,
+// and must not add a local tx (see TransactionPool.Push).
+func (l *txPricedList) add(tx *Transaction) {
+	heap.Push(&l.items, tx)
+}
+
+// remove drops tx from the index, if it is present, in O(log n) via
+// pricedHeap.index rather than scanning every indexed tx for it. It is a
+// no-op for a tx that was never added (e.g. a local tx), matching the
+// pool's usage pattern of calling remove unconditionally on every removal
+// path.
+func (l *txPricedList) remove(tx *Transaction) {
+	i, ok := l.items.index[tx.hash.Hex()]
+	if !ok {
+		return
+	}
+	heap.Remove(&l.items, i)
+}
+
+// cheapest returns the lowest-priced indexed transaction without removing
+// it, or nil if the index is empty.
+func (l *txPricedList) cheapest() *Transaction {
+	if len(l.items.slice) == 0 {
+		return nil
+	}
+	return l.items.slice[0]
+}
+
+// pop removes and returns the lowest-priced indexed transaction, or nil if
+// the index is empty.
+func (l *txPricedList) pop() *Transaction {
+	if len(l.items.slice) == 0 {
+		return nil
+	}
+	return heap.Pop(&l.items).(*Transaction)
+}
+
+// len reports how many transactions are currently indexed.
+func (l *txPricedList) len() int {
+	return len(l.items.slice)
+}
+
+// pricedHeap implements container/heap.Interface over *Transaction, ordered
+// by ascending gas price. index tracks each indexed tx's current slice
+// position (by hash), kept up to date by Swap/Push/Pop, so remove can go
+// straight to heap.Remove instead of scanning slice for the tx first.
+type pricedHeap struct {
+	slice []*Transaction
+	index map[string]int // tx.hash.Hex() -> position in slice
+}
+
+func (h pricedHeap) Len() int { return len(h.slice) }
+
+func (h pricedHeap) Less(i, j int) bool {
+	return h.slice[i].GasPrice().Cmp(h.slice[j].GasPrice()) < 0
+}
+
+func (h pricedHeap) Swap(i, j int) {
+	h.slice[i], h.slice[j] = h.slice[j], h.slice[i]
+	h.index[h.slice[i].hash.Hex()] = i
+	h.index[h.slice[j].hash.Hex()] = j
+}
+
+func (h *pricedHeap) Push(x interface{}) {
+	tx := x.(*Transaction)
+	h.index[tx.hash.Hex()] = len(h.slice)
+	h.slice = append(h.slice, tx)
+}
+
+func (h *pricedHeap) Pop() interface{} {
+	old := h.slice
+	n := len(old)
+	item := old[n-1]
+	h.slice = old[:n-1]
+	delete(h.index, item.hash.Hex())
+	return item
+}
@@ -0,0 +1,218 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package core
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/alexlisong/go-nebulas/crypto/keystore/secp256k1"
+	"github.com/alexlisong/go-nebulas/util/byteutils"
+)
+
+// ErrInvalidSigLength is returned by SignatureValues when sig is not a
+// 65-byte recoverable secp256k1 signature (R || S || recovery id).
+var ErrInvalidSigLength = errors.New("invalid signature length, want 65 bytes")
+
+// ErrInvalidSig is returned by a Signer when a transaction's signature does
+// not recover to a sender address, or was produced by a different Signer
+// than the one asked to verify it (e.g. a ChainIDSigner signature checked by
+// a HomesteadSigner, or vice versa).
+var ErrInvalidSig = errors.New("invalid transaction signature")
+
+// ChainConfig carries the per-chain fork schedule that MakeSigner consults.
+// Only the ChainIDSigner activation height is needed so far; later forks
+// should be added here rather than threaded through call sites individually.
+type ChainConfig struct {
+	// ChainIDSignerHeight is the block height at and after which
+	// transactions must be signed with a ChainIDSigner. A zero value means
+	// the chain never activates it and stays on HomesteadSigner.
+	ChainIDSignerHeight uint64
+}
+
+// Signer encapsulates how a transaction's signing hash is derived and how a
+// signature over it is turned back into the sending Address, so that
+// VerifyIntegrity and TransactionPool.Push can be upgraded to new signature
+// schemes (e.g. folding chainID into the hash) without hardcoding the
+// recovery logic at every call site. MakeSigner picks the Signer a given
+// transaction must satisfy based on the chain's configured fork height.
+type Signer interface {
+	// Hash returns the hash tx's signature is computed over.
+	Hash(tx *Transaction) byteutils.Hash
+
+	// Sender recovers and returns the address that produced tx's signature,
+	// or ErrInvalidSig if it does not verify under this Signer.
+	Sender(tx *Transaction) (*Address, error)
+
+	// Equal reports whether s and other select the same signing rules, so a
+	// signature produced under one Signer implementation is never accepted
+	// by a differently-configured instance of the same kind.
+	Equal(other Signer) bool
+
+	// SignatureValues decomposes sig, a 65-byte recoverable secp256k1
+	// signature produced over Hash(tx), into its r/s/v components, encoding
+	// v the way this Signer expects a replay-protected signature to encode
+	// it (see HomesteadSigner/ChainIDSigner for the concrete schemes).
+	SignatureValues(tx *Transaction, sig []byte) (r, s, v *big.Int, err error)
+}
+
+// HomesteadSigner is the original go-nebulas signing scheme: the signature
+// covers tx.Hash() alone, with no chainID folded in. It is kept as the
+// default so transactions signed before a chain activates ChainIDSigner
+// continue to verify.
+type HomesteadSigner struct{}
+
+// Hash returns tx's plain hash, unmodified by chain identity.
+func (s HomesteadSigner) Hash(tx *Transaction) byteutils.Hash {
+	return tx.Hash()
+}
+
+// Sender recovers tx's sender under the homestead scheme: the public key
+// that produced tx.sign over s.Hash(tx), which must match tx's own declared
+// From address.
+func (s HomesteadSigner) Sender(tx *Transaction) (*Address, error) {
+	return recoverSender(tx, s.Hash(tx))
+}
+
+// Equal reports whether other is also a HomesteadSigner.
+func (s HomesteadSigner) Equal(other Signer) bool {
+	_, ok := other.(HomesteadSigner)
+	return ok
+}
+
+// SignatureValues decomposes sig using the legacy (pre-EIP-155) encoding:
+// v is simply the recovery id shifted into Ethereum's historical 27/28
+// range, with no chain binding at all.
+func (s HomesteadSigner) SignatureValues(tx *Transaction, sig []byte) (r, s2, v *big.Int, err error) {
+	r, s2, recid, err := splitSignature(sig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return r, s2, new(big.Int).SetUint64(uint64(recid) + 27), nil
+}
+
+// ChainIDSigner binds verification to one chain by folding ChainID into the
+// signing hash itself (chainIDSignerHashSalt below), mirroring EIP-155: a
+// signature produced for chain A recovers to a completely different, wrong
+// address if replayed against chain B's ChainIDSigner, rather than relying
+// solely on the tx.ChainID() field comparison in Sender, which by itself
+// binds nothing cryptographically (a signature valid under one ChainID
+// would otherwise verify equally well under any other, since the bytes
+// actually signed never depended on it).
+//
+// NOTE: Transaction.Sign/VerifySign (outside this tree) are not yet known to
+// call Signer.Hash() when producing/checking tx.sign; until whoever owns
+// that code wires them through the Signer this Hash fold picked for a given
+// height, a tx actually signed over the plain tx.Hash() will fail
+// ChainIDSigner.Sender's recovery once ChainIDSignerHeight is active.
+type ChainIDSigner struct {
+	ChainID uint32
+}
+
+// chainIDSignerHashSalt namespaces ChainIDSigner's hash fold so it can never
+// collide with a HomesteadSigner hash produced from unrelated input.
+var chainIDSignerHashSalt = []byte("nebulas-chainid-signer-v1")
+
+// Hash folds ChainID into tx's plain hash via sha256(salt || chainID ||
+// tx.Hash()), so the bytes actually signed differ from HomesteadSigner.Hash
+// and depend on ChainID.
+func (s ChainIDSigner) Hash(tx *Transaction) byteutils.Hash {
+	buf := make([]byte, 0, len(chainIDSignerHashSalt)+4+len(tx.Hash()))
+	buf = append(buf, chainIDSignerHashSalt...)
+	buf = append(buf, byte(s.ChainID>>24), byte(s.ChainID>>16), byte(s.ChainID>>8), byte(s.ChainID))
+	buf = append(buf, tx.Hash()...)
+	sum := sha256.Sum256(buf)
+	return sum[:]
+}
+
+// Sender recovers tx's sender under the chainID-bound scheme, rejecting a
+// transaction whose declared chainID does not match this Signer's before
+// ever attempting recovery.
+func (s ChainIDSigner) Sender(tx *Transaction) (*Address, error) {
+	if tx.ChainID() != s.ChainID {
+		return nil, ErrInvalidSig
+	}
+	return recoverSender(tx, s.Hash(tx))
+}
+
+// Equal reports whether other is a ChainIDSigner configured for the same
+// ChainID.
+func (s ChainIDSigner) Equal(other Signer) bool {
+	o, ok := other.(ChainIDSigner)
+	return ok && o.ChainID == s.ChainID
+}
+
+// SignatureValues decomposes sig using EIP-155's encoding: v commits to
+// ChainID (v = 2*ChainID+35+recid) so a signature cannot be replayed as a
+// legacy, chain-agnostic one either.
+func (s ChainIDSigner) SignatureValues(tx *Transaction, sig []byte) (r, s2, v *big.Int, err error) {
+	r, s2, recid, err := splitSignature(sig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	v = new(big.Int).SetUint64(uint64(s.ChainID)*2 + 35 + uint64(recid))
+	return r, s2, v, nil
+}
+
+// recoverSender recovers the secp256k1 public key that produced tx.sign over
+// hash and returns the Address it derives, or ErrInvalidSig if recovery
+// fails or the recovered address does not match tx's own declared From. It
+// is shared by every Signer implementation: they differ only in which hash
+// they recover against and what gates (if any) run before recovery, not in
+// how recovery itself works.
+func recoverSender(tx *Transaction, hash byteutils.Hash) (*Address, error) {
+	if len(tx.sign) == 0 {
+		return nil, ErrInvalidSig
+	}
+	pub, err := secp256k1.RecoverPubkey(hash, tx.sign)
+	if err != nil {
+		return nil, ErrInvalidSig
+	}
+	addr, err := NewAddressFromPublicKey(pub)
+	if err != nil {
+		return nil, ErrInvalidSig
+	}
+	if !addr.Equals(tx.From()) {
+		return nil, ErrInvalidSig
+	}
+	return addr, nil
+}
+
+// splitSignature splits a 65-byte recoverable secp256k1 signature (R || S ||
+// recovery id) into its r/s big.Ints and recovery id byte.
+func splitSignature(sig []byte) (r, s *big.Int, recid byte, err error) {
+	if len(sig) != 65 {
+		return nil, nil, 0, ErrInvalidSigLength
+	}
+	r = new(big.Int).SetBytes(sig[:32])
+	s = new(big.Int).SetBytes(sig[32:64])
+	recid = sig[64]
+	return r, s, recid, nil
+}
+
+// MakeSigner returns the Signer transactions included at height must
+// satisfy: ChainIDSigner once height reaches conf.ChainIDSignerHeight (and
+// conf declares one by setting it non-zero), HomesteadSigner before that.
+func MakeSigner(conf *ChainConfig, chainID uint32, height uint64) Signer {
+	if conf != nil && conf.ChainIDSignerHeight > 0 && height >= conf.ChainIDSignerHeight {
+		return ChainIDSigner{ChainID: chainID}
+	}
+	return HomesteadSigner{}
+}
@@ -0,0 +1,219 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package net
+
+import (
+	"net"
+	"sync"
+)
+
+// EclipseGuardConfig holds the config-driven thresholds that defend the
+// route table and the inbound/outbound connection pools against an eclipse
+// attack, where an adversary tries to surround a node with peers it
+// controls. Defaults match the policy sketched in the NodeInfo RPC handler's
+// comment: no more than 10 inbound peers per IP, refuse outbound dials to an
+// already-connected IP, no more than 2 peers sharing a /24 per k-bucket, and
+// no more than 10 peers sharing a /24 across the whole route table.
+type EclipseGuardConfig struct {
+	MaxInboundPeersPerIP        int
+	MaxPeersPerSlash24PerBucket int
+	MaxPeersPerSlash24Total     int
+}
+
+// DefaultEclipseGuardConfig returns the policy's out-of-the-box thresholds.
+func DefaultEclipseGuardConfig() *EclipseGuardConfig {
+	return &EclipseGuardConfig{
+		MaxInboundPeersPerIP:        10,
+		MaxPeersPerSlash24PerBucket: 2,
+		MaxPeersPerSlash24Total:     10,
+	}
+}
+
+// EclipseGuard decides, against an EclipseGuardConfig, whether an inbound
+// connection, outbound dial, or route table entry should be allowed, and
+// counts rejections so they can be surfaced through NodeInfo. Note that
+// AllowInbound/AllowOutbound/AllowRouteTableEntry are not yet called from
+// this node's connection-accept/dial/route-sync path (see the FIXME on
+// rpc.AdminService.NodeInfo), so today this only reports what it would
+// reject, not what it has rejected.
+type EclipseGuard struct {
+	mu sync.RWMutex
+
+	cfg *EclipseGuardConfig
+
+	inboundByIP  map[string]int
+	slash24Total map[string]int
+
+	rejectedInbound  uint64
+	rejectedOutbound uint64
+	rejectedRoute    uint64
+}
+
+// NewEclipseGuard builds an EclipseGuard from cfg, falling back to
+// DefaultEclipseGuardConfig when cfg is nil.
+func NewEclipseGuard(cfg *EclipseGuardConfig) *EclipseGuard {
+	if cfg == nil {
+		cfg = DefaultEclipseGuardConfig()
+	}
+	return &EclipseGuard{
+		cfg:          cfg,
+		inboundByIP:  make(map[string]int),
+		slash24Total: make(map[string]int),
+	}
+}
+
+// Config returns the guard's current thresholds.
+func (g *EclipseGuard) Config() EclipseGuardConfig {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return *g.cfg
+}
+
+// SetConfig replaces the guard's thresholds at runtime, e.g. via an admin
+// RPC, without requiring a node restart.
+func (g *EclipseGuard) SetConfig(cfg EclipseGuardConfig) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.cfg = &cfg
+}
+
+// AllowInbound reports whether a new inbound connection from addr should be
+// accepted, i.e. whether accepting it would keep this IP at or under
+// MaxInboundPeersPerIP.
+func (g *EclipseGuard) AllowInbound(addr net.Addr) bool {
+	ip := hostOf(addr)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.inboundByIP[ip] >= g.cfg.MaxInboundPeersPerIP {
+		g.rejectedInbound++
+		return false
+	}
+	g.inboundByIP[ip]++
+	return true
+}
+
+// ReleaseInbound returns the inbound slot addr was holding, called when that
+// connection closes.
+func (g *EclipseGuard) ReleaseInbound(addr net.Addr) {
+	ip := hostOf(addr)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.inboundByIP[ip] > 0 {
+		g.inboundByIP[ip]--
+	}
+}
+
+// AllowOutbound reports whether the node should dial addr, refusing the dial
+// outright if connectedIPs already holds this address's IP.
+func (g *EclipseGuard) AllowOutbound(addr net.Addr, connectedIPs map[string]bool) bool {
+	ip := hostOf(addr)
+	if connectedIPs[ip] {
+		g.mu.Lock()
+		g.rejectedOutbound++
+		g.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// AllowRouteTableEntry reports whether addr may be added to bucketPeers (the
+// existing peers of the k-bucket addr would land in), enforcing both the
+// per-bucket and whole-table /24 caps.
+func (g *EclipseGuard) AllowRouteTableEntry(addr net.Addr, bucketPeers []net.Addr) bool {
+	slash24 := slash24Of(addr)
+
+	inBucket := 0
+	for _, p := range bucketPeers {
+		if slash24Of(p) == slash24 {
+			inBucket++
+		}
+	}
+	if inBucket >= g.cfg.MaxPeersPerSlash24PerBucket {
+		g.mu.Lock()
+		g.rejectedRoute++
+		g.mu.Unlock()
+		return false
+	}
+
+	g.mu.RLock()
+	total := g.slash24Total[slash24]
+	g.mu.RUnlock()
+	if total >= g.cfg.MaxPeersPerSlash24Total {
+		g.mu.Lock()
+		g.rejectedRoute++
+		g.mu.Unlock()
+		return false
+	}
+
+	g.mu.Lock()
+	g.slash24Total[slash24]++
+	g.mu.Unlock()
+	return true
+}
+
+// Metrics is a snapshot of the guard's current per-/24 counts and
+// rejected-connection totals, surfaced through NodeInfo.
+type Metrics struct {
+	InboundPeersByIP map[string]int
+	PeersBySlash24   map[string]int
+	RejectedInbound  uint64
+	RejectedOutbound uint64
+	RejectedRoute    uint64
+}
+
+// Snapshot returns the guard's current Metrics.
+func (g *EclipseGuard) Snapshot() Metrics {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	byIP := make(map[string]int, len(g.inboundByIP))
+	for k, v := range g.inboundByIP {
+		byIP[k] = v
+	}
+	bySlash24 := make(map[string]int, len(g.slash24Total))
+	for k, v := range g.slash24Total {
+		bySlash24[k] = v
+	}
+
+	return Metrics{
+		InboundPeersByIP: byIP,
+		PeersBySlash24:   bySlash24,
+		RejectedInbound:  g.rejectedInbound,
+		RejectedOutbound: g.rejectedOutbound,
+		RejectedRoute:    g.rejectedRoute,
+	}
+}
+
+func hostOf(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+func slash24Of(addr net.Addr) string {
+	host := hostOf(addr)
+	ip := net.ParseIP(host)
+	if ip == nil || ip.To4() == nil {
+		return host
+	}
+	ip4 := ip.To4()
+	return net.IPv4(ip4[0], ip4[1], ip4[2], 0).String() + "/24"
+}
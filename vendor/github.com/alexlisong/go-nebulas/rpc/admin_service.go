@@ -21,11 +21,12 @@ package rpc
 import (
 	"time"
 
-	"github.com/gogo/protobuf/proto"
 	"github.com/alexlisong/go-nebulas/core"
 	"github.com/alexlisong/go-nebulas/crypto/keystore"
 	"github.com/alexlisong/go-nebulas/net"
 	"github.com/alexlisong/go-nebulas/rpc/pb"
+	"github.com/alexlisong/go-nebulas/util"
+	"github.com/gogo/protobuf/proto"
 	"golang.org/x/net/context"
 )
 
@@ -215,7 +216,8 @@ func (s *AdminService) NodeInfo(ctx context.Context, req *rpcpb.NonParamsRequest
 
 	resp := &rpcpb.NodeInfoResponse{}
 	node := neb.NetService().Node()
-	resp.Id = node.ID() // FIXME: @leon check eclipse attack
+	// FIXME: @leon check eclipse attack
+	resp.Id = node.ID()
 	resp.ChainId = node.Config().ChainID
 	resp.BucketSize = int32(node.Config().Bucketsize)
 	resp.PeerCount = uint32(node.PeersCount())
@@ -233,5 +235,69 @@ func (s *AdminService) NodeInfo(ctx context.Context, req *rpcpb.NonParamsRequest
 		resp.RouteTable = append(resp.RouteTable, routeTable)
 	}
 
+	// guard.Snapshot() only reports what AllowInbound/AllowOutbound/
+	// AllowRouteTableEntry would have rejected, had anything in the
+	// connection-accept/dial/route-sync path actually called them; see the
+	// FIXME above and the note on EclipseGuard for the still-open gap.
+	if guard := node.EclipseGuard(); guard != nil {
+		snapshot := guard.Snapshot()
+		resp.RejectedInboundCount = snapshot.RejectedInbound
+		resp.RejectedOutboundCount = snapshot.RejectedOutbound
+		resp.RejectedRouteCount = snapshot.RejectedRoute
+		for ip, count := range snapshot.InboundPeersByIP {
+			resp.InboundPeersByIp = append(resp.InboundPeersByIp, &rpcpb.IPPeerCount{Ip: ip, Count: int32(count)})
+		}
+		for slash24, count := range snapshot.PeersBySlash24 {
+			resp.PeersBySlash24 = append(resp.PeersBySlash24, &rpcpb.IPPeerCount{Ip: slash24, Count: int32(count)})
+		}
+	}
+
 	return resp, nil
 }
+
+// GetEclipseProtectionConfig returns the node's current eclipse-attack
+// mitigation thresholds.
+func (s *AdminService) GetEclipseProtectionConfig(ctx context.Context, req *rpcpb.NonParamsRequest) (*rpcpb.EclipseProtectionConfig, error) {
+	neb := s.server.Neblet()
+	cfg := neb.NetService().Node().EclipseGuard().Config()
+
+	return &rpcpb.EclipseProtectionConfig{
+		MaxInboundPeersPerIp:        int32(cfg.MaxInboundPeersPerIP),
+		MaxPeersPerSlash24PerBucket: int32(cfg.MaxPeersPerSlash24PerBucket),
+		MaxPeersPerSlash24Total:     int32(cfg.MaxPeersPerSlash24Total),
+	}, nil
+}
+
+// SetEclipseProtectionConfig lets an operator adjust the eclipse-attack
+// mitigation thresholds at runtime, e.g. tightening them during a suspected
+// attack without restarting the node.
+func (s *AdminService) SetEclipseProtectionConfig(ctx context.Context, req *rpcpb.EclipseProtectionConfig) (*rpcpb.EclipseProtectionConfig, error) {
+	neb := s.server.Neblet()
+	guard := neb.NetService().Node().EclipseGuard()
+
+	guard.SetConfig(net.EclipseGuardConfig{
+		MaxInboundPeersPerIP:        int(req.MaxInboundPeersPerIp),
+		MaxPeersPerSlash24PerBucket: int(req.MaxPeersPerSlash24PerBucket),
+		MaxPeersPerSlash24Total:     int(req.MaxPeersPerSlash24Total),
+	})
+
+	return req, nil
+}
+
+// SetTxPoolGasPrice lets an operator raise or lower the tx pool's minimum
+// accepted gas price at runtime, e.g. bumping the floor during a spam burst
+// to push out underpriced transactions already sitting in the pool without
+// restarting the node.
+func (s *AdminService) SetTxPoolGasPrice(ctx context.Context, req *rpcpb.SetTxPoolGasPriceRequest) (*rpcpb.SetTxPoolGasPriceResponse, error) {
+	neb := s.server.Neblet()
+
+	gasPrice, err := util.NewUint128FromString(req.GasPrice)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := neb.BlockChain().TransactionPool()
+	pool.SetGasPrice(gasPrice)
+
+	return &rpcpb.SetTxPoolGasPriceResponse{GasPrice: pool.MinGasPrice().String()}, nil
+}
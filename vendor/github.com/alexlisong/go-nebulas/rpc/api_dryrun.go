@@ -0,0 +1,116 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package rpc
+
+import (
+	"time"
+
+	"github.com/alexlisong/go-nebulas/core"
+	"github.com/alexlisong/go-nebulas/rpc/pb"
+	"golang.org/x/net/context"
+)
+
+// DryRunRequest/Response and TraceFrame are new rpcpb messages, defined in
+// the same externally-vendored rpcpb package as everything else this
+// service's handlers take and return — not a package this file owns or can
+// define.
+
+// defaultDryRunTimeout bounds a DryRunCall when the request leaves
+// TimeoutMs unset, so a client can never wedge the RPC goroutine pool by
+// omitting it.
+const defaultDryRunTimeout = 5 * time.Second
+
+// DryRunCall is the RPC API handler for eth_call-style contract querying: it
+// runs req as a dry run of CallPayload.DryRun against a throwaway WorldState
+// clone, so the result reflects what the call would do without requiring a
+// signed transaction or mutating chain state. req.From overrides the
+// transaction sender, letting a client simulate a call from any address;
+// req.Height, like Call, runs against that historical block instead of the
+// tail.
+func (s *APIService) DryRunCall(ctx context.Context, req *rpcpb.DryRunRequest) (*rpcpb.DryRunResponse, error) {
+	neb := s.server.Neblet()
+
+	tx, err := parseTransaction(neb, req.Transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	block := neb.BlockChain().TailBlock()
+	if req.Height > 0 {
+		block = neb.BlockChain().GetBlockOnCanonicalChainByHeight(req.Height)
+	}
+	if block == nil {
+		return nil, core.ErrBlockNotFoundForSimulate
+	}
+
+	var from *core.Address
+	if req.From != "" {
+		from, err = core.AddressParse(req.From)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	payload, err := core.LoadCallPayload(tx.Data().Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := defaultDryRunTimeout
+	if req.TimeoutMs > 0 {
+		timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+	}
+
+	ws, err := block.WorldState().Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	frame, err := payload.DryRun(tx.GasLimit(), tx, block, ws, from, timeout)
+	if err != nil && err != core.ErrDryRunTimeout {
+		return nil, err
+	}
+
+	return &rpcpb.DryRunResponse{Trace: toTraceResponse(frame)}, nil
+}
+
+// toTraceResponse converts a core.TraceFrame into its wire representation,
+// recursing into sub-calls so the client gets the whole call tree in one
+// response.
+func toTraceResponse(frame *core.TraceFrame) *rpcpb.TraceFrame {
+	if frame == nil {
+		return nil
+	}
+
+	resp := &rpcpb.TraceFrame{
+		Function:    frame.Function,
+		Args:        frame.Args,
+		StateReads:  frame.StateReads,
+		StateWrites: frame.StateWrites,
+		Events:      frame.Events,
+		Error:       frame.Error,
+	}
+	if frame.GasUsed != nil {
+		resp.GasUsed = frame.GasUsed.String()
+	}
+	for _, sub := range frame.SubCalls {
+		resp.SubCalls = append(resp.SubCalls, toTraceResponse(sub))
+	}
+	return resp
+}
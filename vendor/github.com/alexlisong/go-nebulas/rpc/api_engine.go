@@ -0,0 +1,152 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package rpc
+
+import (
+	"errors"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/alexlisong/go-nebulas/core"
+	"github.com/alexlisong/go-nebulas/core/pb"
+	"github.com/alexlisong/go-nebulas/rpc/pb"
+	"github.com/alexlisong/go-nebulas/util/byteutils"
+	"golang.org/x/net/context"
+)
+
+// The rpcpb.NewPayload*/ForkchoiceUpdated*/GetPayload* request/response
+// types below are declared in the rpcpb package alongside the rest of this
+// service's messages (rpcpb.GetNebStateResponse, rpcpb.TransactionRequest,
+// etc. in api_service.go) — this file only adds new handlers and the
+// message types they need, the same way every Engine-API-style addition to
+// this package has.
+
+// NewPayload validates and imports a fully-formed, already-signed block that
+// was produced outside of the local dpos miner (e.g. by an external
+// consensus driver, simulator or L2 sequencer). It mirrors the
+// engine_newPayload call from Ethereum's Engine API: the caller supplies the
+// raw block bytes, this node verifies them and links them onto the chain,
+// but does not by itself change the canonical head.
+func (s *APIService) NewPayload(ctx context.Context, req *rpcpb.NewPayloadRequest) (*rpcpb.NewPayloadResponse, error) {
+	neb := s.server.Neblet()
+
+	pbBlock := new(corepb.Block)
+	if err := proto.Unmarshal(req.Payload, pbBlock); err != nil {
+		return nil, err
+	}
+	block := new(core.Block)
+	if err := block.FromProto(pbBlock); err != nil {
+		return nil, err
+	}
+
+	parent := neb.BlockChain().GetBlock(block.ParentHash())
+	if parent == nil {
+		return &rpcpb.NewPayloadResponse{Status: "INVALID", Hash: block.Hash().String(), Error: "parent block not found"}, nil
+	}
+
+	if err := block.LinkParentBlock(neb.BlockChain(), parent); err != nil {
+		return &rpcpb.NewPayloadResponse{Status: "INVALID", Hash: block.Hash().String(), Error: err.Error()}, nil
+	}
+	if err := block.VerifyExecution(); err != nil {
+		return &rpcpb.NewPayloadResponse{Status: "INVALID", Hash: block.Hash().String(), Error: err.Error()}, nil
+	}
+	if err := neb.BlockChain().BlockPool().PushAndRelay(block); err != nil {
+		return &rpcpb.NewPayloadResponse{Status: "INVALID", Hash: block.Hash().String(), Error: err.Error()}, nil
+	}
+
+	return &rpcpb.NewPayloadResponse{Status: "VALID", Hash: block.Hash().String()}, nil
+}
+
+// ForkchoiceUpdated forces the canonical chain to re-org to headHash and, if
+// libHash is a descendant of the current LIB, advances the LIB pointer to
+// it. It lets an external consensus driver steer the chain over RPC instead
+// of relying on the in-process dpos fork-choice loop.
+func (s *APIService) ForkchoiceUpdated(ctx context.Context, req *rpcpb.ForkchoiceUpdatedRequest) (*rpcpb.ForkchoiceUpdatedResponse, error) {
+	neb := s.server.Neblet()
+
+	headHash, err := byteutils.FromHex(req.HeadHash)
+	if err != nil {
+		return nil, err
+	}
+	head := neb.BlockChain().GetBlock(headHash)
+	if head == nil {
+		return nil, errors.New("head block not found")
+	}
+	if err := neb.BlockChain().SetTailBlock(head); err != nil {
+		return nil, err
+	}
+
+	if req.LibHash != "" {
+		libHash, err := byteutils.FromHex(req.LibHash)
+		if err != nil {
+			return nil, err
+		}
+		lib := neb.BlockChain().GetBlock(libHash)
+		if lib == nil {
+			return nil, errors.New("lib block not found")
+		}
+		if !neb.BlockChain().IsLIBAncestor(lib, head) {
+			return nil, errors.New("lib is not an ancestor of the new head")
+		}
+		if err := neb.BlockChain().StoreLIBBlock(lib); err != nil {
+			return nil, err
+		}
+	}
+
+	return &rpcpb.ForkchoiceUpdatedResponse{Status: "VALID"}, nil
+}
+
+// GetPayload assembles and returns an unsealed candidate block built from
+// the current tx pool on top of parentHash, so an external consensus driver
+// can seal and re-submit it via NewPayload rather than relying on the local
+// dpos miner to own block production.
+func (s *APIService) GetPayload(ctx context.Context, req *rpcpb.GetPayloadRequest) (*rpcpb.GetPayloadResponse, error) {
+	neb := s.server.Neblet()
+
+	parentHash, err := byteutils.FromHex(req.ParentHash)
+	if err != nil {
+		return nil, err
+	}
+	parent := neb.BlockChain().GetBlock(parentHash)
+	if parent == nil {
+		return nil, errors.New("parent block not found")
+	}
+
+	coinbase, err := core.AddressParse(req.Coinbase)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := core.NewBlock(neb.BlockChain().ChainID(), coinbase, parent)
+	if err != nil {
+		return nil, err
+	}
+	block.SetTimestamp(req.Timestamp)
+	block.CollectTransactions(neb.BlockChain().TransactionPool(), block.Timestamp())
+
+	pbBlock, err := block.ToProto()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := proto.Marshal(pbBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rpcpb.GetPayloadResponse{Payload: payload}, nil
+}
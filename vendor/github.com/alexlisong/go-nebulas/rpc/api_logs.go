@@ -0,0 +1,264 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package rpc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+
+	"github.com/alexlisong/go-nebulas/core"
+	"github.com/alexlisong/go-nebulas/rpc/pb"
+	"golang.org/x/net/context"
+)
+
+// FilterQuery, GetLogsResponse, LogResponse, NewFilterResponse,
+// FilterChangesRequest and UninstallFilterResponse are new messages added to
+// rpcpb — the same externally-vendored package every other handler in this
+// service already relies on, not one this file is responsible for standing
+// up from scratch.
+
+// GetLogs is the RPC API handler for querying the contract-event log index
+// built by core.ActiveLogIndex. fromBlock/toBlock accept a decimal height or
+// the "latest"/"pending" sentinels, matching Ethereum's eth_getLogs; "latest"
+// and "pending" both resolve to the current tail, since go-nebulas has no
+// separate pending block to query logs against.
+func (s *APIService) GetLogs(ctx context.Context, req *rpcpb.FilterQuery) (*rpcpb.GetLogsResponse, error) {
+	neb := s.server.Neblet()
+	if core.ActiveLogIndex == nil {
+		return nil, ErrLogIndexDisabled
+	}
+
+	tail := neb.BlockChain().TailBlock().Height()
+	query, err := toFilterQuery(req, tail)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := core.ActiveLogIndex.GetLogs(query, tail)
+	if err != nil {
+		return nil, err
+	}
+	return &rpcpb.GetLogsResponse{Logs: toLogResponses(logs)}, nil
+}
+
+// ErrLogIndexDisabled is returned by GetLogs and the filter RPCs when the
+// node was started without the log index (core.ActiveLogIndex is nil),
+// rather than silently answering an empty result set.
+var ErrLogIndexDisabled = errLogIndexDisabled{}
+
+type errLogIndexDisabled struct{}
+
+func (errLogIndexDisabled) Error() string {
+	return "log index is not enabled on this node"
+}
+
+func toFilterQuery(req *rpcpb.FilterQuery, tail uint64) (*core.FilterQuery, error) {
+	from, _, err := parseBlockSentinel(req.FromBlock, tail)
+	if err != nil {
+		return nil, err
+	}
+	to, toLatest, err := parseBlockSentinel(req.ToBlock, tail)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]*core.Address, 0, len(req.Addresses))
+	for _, a := range req.Addresses {
+		addr, err := core.AddressParse(a)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+
+	topics := make([][][]byte, len(req.Topics))
+	for i, set := range req.Topics {
+		alts := make([][]byte, len(set.Values))
+		for j, v := range set.Values {
+			alts[j] = []byte(v)
+		}
+		topics[i] = alts
+	}
+
+	return &core.FilterQuery{
+		FromBlock:     from,
+		ToBlock:       to,
+		ToBlockLatest: toLatest,
+		Addresses:     addrs,
+		Topics:        topics,
+	}, nil
+}
+
+// parseBlockSentinel parses a block height that may be "latest", "pending",
+// or a decimal string, returning tail as the height either sentinel resolves
+// to at call time and latest=true so the caller can re-resolve it against a
+// fresher tail (GetLogs itself does this for ToBlock).
+func parseBlockSentinel(s string, tail uint64) (height uint64, latest bool, err error) {
+	switch s {
+	case "", "latest", "pending":
+		return tail, true, nil
+	default:
+		height, err = strconv.ParseUint(s, 10, 64)
+		return height, false, err
+	}
+}
+
+func toLogResponses(logs []*core.Log) []*rpcpb.LogResponse {
+	out := make([]*rpcpb.LogResponse, 0, len(logs))
+	for _, log := range logs {
+		topics := make([]string, len(log.Topics))
+		for i, t := range log.Topics {
+			topics[i] = string(t)
+		}
+		out = append(out, &rpcpb.LogResponse{
+			BlockHeight: log.BlockHeight,
+			TxHash:      string(log.TxHash),
+			TxIndex:     log.TxIndex,
+			LogIndex:    log.LogIndex,
+			Address:     log.Address.String(),
+			Topics:      topics,
+			Data:        string(log.Data),
+		})
+	}
+	return out
+}
+
+// installedFilter is a server-side standing query created by NewFilter: it
+// remembers the height GetFilterChanges last delivered up to, so repeated
+// polling only returns logs the client hasn't seen.
+type installedFilter struct {
+	query      *core.FilterQuery
+	lastHeight uint64
+}
+
+// FilterManager backs the newFilter/getFilterChanges/uninstallFilter RPCs,
+// letting a light client install a standing FilterQuery once and poll for
+// new matches instead of re-scanning the full range on every call.
+type FilterManager struct {
+	mu      sync.Mutex
+	filters map[string]*installedFilter
+}
+
+// NewFilterManager returns an empty FilterManager.
+func NewFilterManager() *FilterManager {
+	return &FilterManager{filters: make(map[string]*installedFilter)}
+}
+
+// NewFilter installs query, starting from its FromBlock, and returns the
+// opaque id later passed to GetFilterChanges/UninstallFilter.
+func (m *FilterManager) NewFilter(query *core.FilterQuery) string {
+	id := newFilterID()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filters[id] = &installedFilter{query: query, lastHeight: query.FromBlock}
+	return id
+}
+
+// GetFilterChanges returns the logs matching id's query that have appeared
+// since the last call (or since NewFilter, for the first call), advancing
+// the filter's watermark to tail so the next call only sees newer logs.
+func (m *FilterManager) GetFilterChanges(id string, tail uint64) ([]*core.Log, error) {
+	m.mu.Lock()
+	f, ok := m.filters[id]
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrFilterNotFound
+	}
+
+	query := *f.query
+	query.FromBlock = f.lastHeight
+	query.ToBlock = tail
+	query.ToBlockLatest = false
+
+	logs, err := core.ActiveLogIndex.GetLogs(&query, tail)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	f.lastHeight = tail + 1
+	m.mu.Unlock()
+	return logs, nil
+}
+
+// UninstallFilter removes id, reporting whether it existed.
+func (m *FilterManager) UninstallFilter(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.filters[id]; !ok {
+		return false
+	}
+	delete(m.filters, id)
+	return true
+}
+
+// ErrFilterNotFound is returned by GetFilterChanges/UninstallFilter for an id
+// that was never installed or was already uninstalled.
+var ErrFilterNotFound = errFilterNotFound{}
+
+type errFilterNotFound struct{}
+
+func (errFilterNotFound) Error() string {
+	return "filter not found"
+}
+
+func newFilterID() string {
+	raw := make([]byte, 16)
+	_, _ = rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
+
+// filters is the process-wide FilterManager backing the newFilter/
+// getFilterChanges/uninstallFilter RPCs below.
+var filters = NewFilterManager()
+
+// NewFilterRPC is the RPC API handler for newFilter: it installs req as a
+// standing query and returns its id.
+func (s *APIService) NewFilterRPC(ctx context.Context, req *rpcpb.FilterQuery) (*rpcpb.NewFilterResponse, error) {
+	if core.ActiveLogIndex == nil {
+		return nil, ErrLogIndexDisabled
+	}
+	tail := s.server.Neblet().BlockChain().TailBlock().Height()
+	query, err := toFilterQuery(req, tail)
+	if err != nil {
+		return nil, err
+	}
+	return &rpcpb.NewFilterResponse{Id: filters.NewFilter(query)}, nil
+}
+
+// GetFilterChangesRPC is the RPC API handler for getFilterChanges.
+func (s *APIService) GetFilterChangesRPC(ctx context.Context, req *rpcpb.FilterChangesRequest) (*rpcpb.GetLogsResponse, error) {
+	if core.ActiveLogIndex == nil {
+		return nil, ErrLogIndexDisabled
+	}
+	tail := s.server.Neblet().BlockChain().TailBlock().Height()
+	logs, err := filters.GetFilterChanges(req.Id, tail)
+	if err != nil {
+		return nil, err
+	}
+	return &rpcpb.GetLogsResponse{Logs: toLogResponses(logs)}, nil
+}
+
+// UninstallFilterRPC is the RPC API handler for uninstallFilter.
+func (s *APIService) UninstallFilterRPC(ctx context.Context, req *rpcpb.FilterChangesRequest) (*rpcpb.UninstallFilterResponse, error) {
+	return &rpcpb.UninstallFilterResponse{Result: filters.UninstallFilter(req.Id)}, nil
+}
@@ -27,17 +27,17 @@ import (
 
 	"encoding/json"
 
-	"github.com/gogo/protobuf/proto"
 	"github.com/alexlisong/go-nebulas/core"
 	"github.com/alexlisong/go-nebulas/core/pb"
 	"github.com/alexlisong/go-nebulas/net"
 	"github.com/alexlisong/go-nebulas/rpc/pb"
 	"github.com/alexlisong/go-nebulas/util"
 	"github.com/alexlisong/go-nebulas/util/byteutils"
+	"github.com/gogo/protobuf/proto"
 	"golang.org/x/net/context"
 )
 
-//the max number of block can be dumped once
+// the max number of block can be dumped once
 const maxDumpBlockCount = 10
 
 // APIService implements the RPC API service interface.
@@ -94,7 +94,10 @@ func (s *APIService) GetAccountState(ctx context.Context, req *rpcpb.GetAccountS
 	return &rpcpb.GetAccountStateResponse{Balance: acc.Balance().String(), Nonce: acc.Nonce(), Type: uint32(addr.Type())}, nil
 }
 
-// Call is the RPC API handler.
+// Call is the RPC API handler. req.Height, a field added to the existing
+// rpcpb.TransactionRequest alongside every other message this service
+// already depends on from the external rpcpb package, lets a caller
+// simulate against a historical block instead of only the tail.
 func (s *APIService) Call(ctx context.Context, req *rpcpb.TransactionRequest) (*rpcpb.CallResponse, error) {
 	neb := s.server.Neblet()
 	tx, err := parseTransaction(neb, req)
@@ -102,7 +105,7 @@ func (s *APIService) Call(ctx context.Context, req *rpcpb.TransactionRequest) (*
 		return nil, err
 	}
 
-	result, err := neb.BlockChain().SimulateTransactionExecution(tx)
+	result, err := neb.BlockChain().SimulateTransactionExecutionAtHeight(tx, req.Height)
 	if err != nil {
 		return nil, err
 	}
@@ -217,8 +220,9 @@ func handleTransactionResponse(neb core.Neblet, tx *core.Transaction) (resp *rpc
 		}
 	}
 
-	// push and broadcast tx
-	if err := neb.BlockChain().TransactionPool().PushAndBroadcast(tx); err != nil {
+	// push tx into the pool; notifyPending fires TopicPendingTransaction so
+	// subscribers learn about it, but nothing here gossips it to peers
+	if err := neb.BlockChain().TransactionPool().Push(tx); err != nil {
 		return nil, err
 	}
 
@@ -402,11 +406,20 @@ func (s *APIService) toTransactionResponse(tx *core.Transaction) (*rpcpb.Transac
 	return resp, nil
 }
 
-// Subscribe ..
+// Subscribe streams events matching req.Topics to the client. Besides the
+// committed-block topics already emitted by the EventEmitter, it accepts the
+// real-time topics core.TopicPendingTransaction, core.TopicPendingTransactionFull,
+// core.TopicChainHead and core.TopicChainReorg, and narrows the stream down
+// with req.Filter so wallets and indexers can follow just the activity they
+// care about without flooding themselves on a busy chain. req.Filter and
+// the new rpcpb.SubscribeFilter type it references both live in the same
+// external rpcpb package rpcpb.SubscribeRequest itself already comes from.
 func (s *APIService) Subscribe(req *rpcpb.SubscribeRequest, gs rpcpb.ApiService_SubscribeServer) error {
 
 	neb := s.server.Neblet()
 
+	filter := toSubscribeFilter(req.Filter)
+
 	eventSub := core.NewEventSubscriber(1024, req.Topics)
 	neb.EventEmitter().Register(eventSub)
 	defer neb.EventEmitter().Deregister(eventSub)
@@ -417,6 +430,9 @@ func (s *APIService) Subscribe(req *rpcpb.SubscribeRequest, gs rpcpb.ApiService_
 		case <-gs.Context().Done():
 			return gs.Context().Err()
 		case event := <-eventSub.EventChan():
+			if !matchesSubscribeFilter(neb, event, filter) {
+				continue
+			}
 			err = gs.Send(&rpcpb.SubscribeResponse{Topic: event.Topic, Data: event.Data})
 			if err != nil {
 				return err
@@ -425,6 +441,43 @@ func (s *APIService) Subscribe(req *rpcpb.SubscribeRequest, gs rpcpb.ApiService_
 	}
 }
 
+func toSubscribeFilter(f *rpcpb.SubscribeFilter) *core.SubscribeFilter {
+	if f == nil {
+		return nil
+	}
+	filter := &core.SubscribeFilter{
+		FromAddress: f.FromAddress,
+		ToAddress:   f.ToAddress,
+	}
+	if f.MinGasPrice != "" {
+		if min, err := util.NewUint128FromString(f.MinGasPrice); err == nil {
+			filter.MinGasPrice = min
+		}
+	}
+	return filter
+}
+
+// matchesSubscribeFilter only inspects pending-tx topics; committed-block
+// topics (logs, new heads, reorgs) are left to pass through unfiltered since
+// they are not keyed by a single from/to address or gas price.
+func matchesSubscribeFilter(neb core.Neblet, event *core.Event, filter *core.SubscribeFilter) bool {
+	if filter == nil {
+		return true
+	}
+	if event.Topic != core.TopicPendingTransaction && event.Topic != core.TopicPendingTransactionFull {
+		return true
+	}
+	hash, err := byteutils.FromHex(event.Data)
+	if err != nil {
+		return true
+	}
+	tx := neb.BlockChain().TransactionPool().GetTransaction(hash)
+	if tx == nil {
+		return true
+	}
+	return filter.MatchTransaction(tx)
+}
+
 // GetGasPrice get gas price from chain.
 func (s *APIService) GetGasPrice(ctx context.Context, req *rpcpb.NonParamsRequest) (*rpcpb.GasPriceResponse, error) {
 	neb := s.server.Neblet()
@@ -440,7 +493,7 @@ func (s *APIService) EstimateGas(ctx context.Context, req *rpcpb.TransactionRequ
 		return nil, err
 	}
 
-	result, err := neb.BlockChain().SimulateTransactionExecution(tx)
+	result, err := neb.BlockChain().SimulateTransactionExecutionAtHeight(tx, req.Height)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,206 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Scope names enforced by the JSON-RPC gateway's auth middleware. Operators
+// map bearer tokens to a set of these in node config, so e.g. a read-only
+// token can be handed out publicly while NewAccount/UnlockAccount/StartPprof
+// stay reachable only from a private port.
+const (
+	ScopeAccountsRead  = "accounts:read"
+	ScopeAccountsWrite = "accounts:write"
+	ScopeChainRead     = "chain:read"
+	ScopeAdminPprof    = "admin:pprof"
+)
+
+// methodScopes maps every JSON-RPC method the gateway exposes to the scope
+// required to call it. admin_* write methods and pprof are deliberately not
+// covered by chain:read/accounts:read so a public, read-only token can never
+// reach them.
+var methodScopes = map[string]string{
+	"admin_accounts":            ScopeAccountsRead,
+	"admin_newAccount":          ScopeAccountsWrite,
+	"admin_unlockAccount":       ScopeAccountsWrite,
+	"admin_lockAccount":         ScopeAccountsWrite,
+	"admin_sendTransaction":     ScopeAccountsWrite,
+	"admin_signHash":            ScopeAccountsWrite,
+	"admin_startPprof":          ScopeAdminPprof,
+	"api_getNebState":           ScopeChainRead,
+	"api_getAccountState":       ScopeChainRead,
+	"api_call":                  ScopeChainRead,
+	"api_sendRawTransaction":    ScopeAccountsWrite,
+	"api_getBlockByHash":        ScopeChainRead,
+	"api_getBlockByHeight":      ScopeChainRead,
+	"api_getTransactionReceipt": ScopeChainRead,
+	"api_estimateGas":           ScopeChainRead,
+}
+
+// rpcRequest is a single JSON-RPC 2.0 call.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      interface{}     `json:"id"`
+}
+
+// rpcError is a JSON-RPC 2.0 error object.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Standard JSON-RPC 2.0 error codes, plus two gateway-specific ones in the
+// implementation-defined -32000..-32099 server-error range.
+const (
+	errCodeParse          = -32700
+	errCodeInvalidRequest = -32600
+	errCodeMethodNotFound = -32601
+	errCodeInternal       = -32603
+	errCodeUnauthorized   = -32001
+)
+
+// rpcResponse is a single JSON-RPC 2.0 reply.
+type rpcResponse struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   *rpcError   `json:"error,omitempty"`
+	ID      interface{} `json:"id"`
+}
+
+// MethodHandler executes a single JSON-RPC method against already-parsed
+// params and returns the JSON-encodable result.
+type MethodHandler func(params json.RawMessage) (interface{}, error)
+
+// JSONRPCGateway layers a batched JSON-RPC 2.0 endpoint over the gRPC
+// AdminService/ApiService handlers, reachable over plain HTTP POST (one
+// object or an array of objects) via ServeHTTP. There is no WebSocket
+// transport: a long-lived subscription connection would need the rpc/pubsub
+// Hub (see its package doc) wired into a transport of its own, which does
+// not exist yet either. A bearer token maps to a set of scopes via
+// tokenScopes, read from node config, so operators can expose the read-only
+// surface publicly while keeping account-mutating and pprof methods on a
+// private port/token.
+type JSONRPCGateway struct {
+	methods     map[string]MethodHandler
+	tokenScopes map[string][]string
+}
+
+// NewJSONRPCGateway builds a gateway with tokenScopes as the token -> scopes
+// mapping (e.g. node config's rpc.jsonrpc.tokens table).
+func NewJSONRPCGateway(tokenScopes map[string][]string) *JSONRPCGateway {
+	return &JSONRPCGateway{
+		methods:     make(map[string]MethodHandler),
+		tokenScopes: tokenScopes,
+	}
+}
+
+// RegisterMethod wires name (e.g. "admin_newAccount") to handler. Methods not
+// registered here answer errCodeMethodNotFound regardless of auth.
+func (g *JSONRPCGateway) RegisterMethod(name string, handler MethodHandler) {
+	g.methods[name] = handler
+}
+
+// ServeHTTP implements http.Handler, accepting both a single JSON-RPC
+// request object and a batch (JSON array of request objects) per the
+// JSON-RPC 2.0 spec, and replying with the correspondingly-shaped response.
+func (g *JSONRPCGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	scopes := g.scopesForRequest(r)
+
+	body := json.NewDecoder(r.Body)
+	var raw json.RawMessage
+	if err := body.Decode(&raw); err != nil {
+		writeJSON(w, rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: errCodeParse, Message: err.Error()}})
+		return
+	}
+
+	trimmed := strings.TrimSpace(string(raw))
+	if strings.HasPrefix(trimmed, "[") {
+		var reqs []rpcRequest
+		if err := json.Unmarshal(raw, &reqs); err != nil {
+			writeJSON(w, rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: errCodeParse, Message: err.Error()}})
+			return
+		}
+		resps := make([]rpcResponse, len(reqs))
+		for i, req := range reqs {
+			resps[i] = g.call(req, scopes)
+		}
+		writeJSON(w, resps)
+		return
+	}
+
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		writeJSON(w, rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: errCodeParse, Message: err.Error()}})
+		return
+	}
+	writeJSON(w, g.call(req, scopes))
+}
+
+func (g *JSONRPCGateway) call(req rpcRequest, scopes map[string]bool) rpcResponse {
+	resp := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+
+	required, known := methodScopes[req.Method]
+	if !known {
+		resp.Error = &rpcError{Code: errCodeMethodNotFound, Message: "method not found: " + req.Method}
+		return resp
+	}
+	if !scopes[required] {
+		resp.Error = &rpcError{Code: errCodeUnauthorized, Message: "token lacks required scope: " + required}
+		return resp
+	}
+
+	handler, ok := g.methods[req.Method]
+	if !ok {
+		resp.Error = &rpcError{Code: errCodeMethodNotFound, Message: "method not found: " + req.Method}
+		return resp
+	}
+
+	result, err := handler(req.Params)
+	if err != nil {
+		resp.Error = &rpcError{Code: errCodeInternal, Message: err.Error()}
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+// scopesForRequest resolves the bearer token on r to its configured scope
+// set. A missing or unrecognized token gets no scopes at all, so every
+// scope-gated method rejects it.
+func (g *JSONRPCGateway) scopesForRequest(r *http.Request) map[string]bool {
+	auth := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(auth, "Bearer ")
+
+	set := make(map[string]bool)
+	for _, scope := range g.tokenScopes[token] {
+		set[scope] = true
+	}
+	return set
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,135 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package rpc
+
+import (
+	"encoding/json"
+
+	"github.com/alexlisong/go-nebulas/rpc/pb"
+	"golang.org/x/net/context"
+)
+
+// NewJSONRPCGatewayFor builds a gateway over admin and api's underlying
+// Neblet, reusing parseTransaction/handleTransactionResponse under the hood
+// exactly like the gRPC handlers do, so the two transports stay behaviorally
+// identical. tokenScopes is the config-driven token -> scopes table.
+func NewJSONRPCGatewayFor(admin *AdminService, api *APIService, tokenScopes map[string][]string) *JSONRPCGateway {
+	gw := NewJSONRPCGateway(tokenScopes)
+
+	gw.RegisterMethod("admin_accounts", func(params json.RawMessage) (interface{}, error) {
+		return admin.Accounts(context.Background(), &rpcpb.NonParamsRequest{})
+	})
+	gw.RegisterMethod("admin_newAccount", func(params json.RawMessage) (interface{}, error) {
+		req := new(rpcpb.NewAccountRequest)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, err
+		}
+		return admin.NewAccount(context.Background(), req)
+	})
+	gw.RegisterMethod("admin_unlockAccount", func(params json.RawMessage) (interface{}, error) {
+		req := new(rpcpb.UnlockAccountRequest)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, err
+		}
+		return admin.UnlockAccount(context.Background(), req)
+	})
+	gw.RegisterMethod("admin_lockAccount", func(params json.RawMessage) (interface{}, error) {
+		req := new(rpcpb.LockAccountRequest)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, err
+		}
+		return admin.LockAccount(context.Background(), req)
+	})
+	gw.RegisterMethod("admin_sendTransaction", func(params json.RawMessage) (interface{}, error) {
+		req := new(rpcpb.TransactionRequest)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, err
+		}
+		return admin.SendTransaction(context.Background(), req)
+	})
+	gw.RegisterMethod("admin_signHash", func(params json.RawMessage) (interface{}, error) {
+		req := new(rpcpb.SignHashRequest)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, err
+		}
+		return admin.SignHash(context.Background(), req)
+	})
+	gw.RegisterMethod("admin_startPprof", func(params json.RawMessage) (interface{}, error) {
+		req := new(rpcpb.PprofRequest)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, err
+		}
+		return admin.StartPprof(context.Background(), req)
+	})
+
+	gw.RegisterMethod("api_getNebState", func(params json.RawMessage) (interface{}, error) {
+		return api.GetNebState(context.Background(), &rpcpb.NonParamsRequest{})
+	})
+	gw.RegisterMethod("api_getAccountState", func(params json.RawMessage) (interface{}, error) {
+		req := new(rpcpb.GetAccountStateRequest)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, err
+		}
+		return api.GetAccountState(context.Background(), req)
+	})
+	gw.RegisterMethod("api_call", func(params json.RawMessage) (interface{}, error) {
+		req := new(rpcpb.TransactionRequest)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, err
+		}
+		return api.Call(context.Background(), req)
+	})
+	gw.RegisterMethod("api_sendRawTransaction", func(params json.RawMessage) (interface{}, error) {
+		req := new(rpcpb.SendRawTransactionRequest)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, err
+		}
+		return api.SendRawTransaction(context.Background(), req)
+	})
+	gw.RegisterMethod("api_getBlockByHash", func(params json.RawMessage) (interface{}, error) {
+		req := new(rpcpb.GetBlockByHashRequest)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, err
+		}
+		return api.GetBlockByHash(context.Background(), req)
+	})
+	gw.RegisterMethod("api_getBlockByHeight", func(params json.RawMessage) (interface{}, error) {
+		req := new(rpcpb.GetBlockByHeightRequest)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, err
+		}
+		return api.GetBlockByHeight(context.Background(), req)
+	})
+	gw.RegisterMethod("api_getTransactionReceipt", func(params json.RawMessage) (interface{}, error) {
+		req := new(rpcpb.GetTransactionByHashRequest)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, err
+		}
+		return api.GetTransactionReceipt(context.Background(), req)
+	})
+	gw.RegisterMethod("api_estimateGas", func(params json.RawMessage) (interface{}, error) {
+		req := new(rpcpb.TransactionRequest)
+		if err := json.Unmarshal(params, req); err != nil {
+			return nil, err
+		}
+		return api.EstimateGas(context.Background(), req)
+	})
+
+	return gw
+}
@@ -0,0 +1,308 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package rpc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/alexlisong/go-nebulas/core"
+	"github.com/alexlisong/go-nebulas/core/pb"
+	"github.com/alexlisong/go-nebulas/crypto/keystore"
+	"github.com/alexlisong/go-nebulas/rpc/pb"
+	"github.com/alexlisong/go-nebulas/storage"
+	"github.com/gogo/protobuf/proto"
+	"golang.org/x/net/context"
+)
+
+// Errors returned by the multisig proposal RPCs.
+var (
+	ErrProposalNotFound = errors.New("multisig proposal not found")
+	ErrProposalExpired  = errors.New("multisig proposal has expired")
+	ErrNotASigner       = errors.New("address is not one of this proposal's signers")
+	ErrAlreadySigned    = errors.New("address has already signed this proposal")
+	ErrThresholdNotMet  = errors.New("multisig proposal has not collected enough signatures yet")
+)
+
+// defaultMultisigExpiry bounds how long a proposal without an explicit
+// ExpirySeconds waits for signatures, so an abandoned proposal doesn't sit
+// in storage forever.
+const defaultMultisigExpiry = 24 * time.Hour
+
+const multisigKeyPrefix = "multisig.proposal."
+
+// multisigProposal is a pending M-of-N threshold-signed transaction: tx sits
+// unsigned until at least threshold of signers have each contributed a
+// partial signature over signingHash via SignMultisigProposal.
+type multisigProposal struct {
+	ID          string
+	TxData      []byte
+	SigningHash []byte
+	Signers     []string
+	Threshold   int
+	Signatures  []*core.MultisigSignature
+	ExpiresAt   int64
+}
+
+func (p *multisigProposal) expired(now int64) bool {
+	return p.ExpiresAt > 0 && now >= p.ExpiresAt
+}
+
+func (p *multisigProposal) isSigner(addr string) bool {
+	for _, s := range p.Signers {
+		if s == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *multisigProposal) hasSigned(addr string) bool {
+	for _, s := range p.Signatures {
+		if s.Address == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *multisigProposal) tx() (*core.Transaction, error) {
+	pbTx := new(corepb.Transaction)
+	if err := proto.Unmarshal(p.TxData, pbTx); err != nil {
+		return nil, err
+	}
+	tx := new(core.Transaction)
+	if err := tx.FromProto(pbTx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// multisigStore persists multisigProposals in the node's chain database so a
+// proposal survives a restart while its signers are still collecting
+// signatures.
+type multisigStore struct {
+	storage storage.Storage
+}
+
+func newMultisigStore(store storage.Storage) *multisigStore {
+	return &multisigStore{storage: store}
+}
+
+func (s *multisigStore) put(p *multisigProposal) error {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return s.storage.Put(multisigStorageKey(p.ID), raw)
+}
+
+func (s *multisigStore) get(id string) (*multisigProposal, error) {
+	raw, err := s.storage.Get(multisigStorageKey(id))
+	if err != nil {
+		if err == storage.ErrKeyNotFound {
+			return nil, ErrProposalNotFound
+		}
+		return nil, err
+	}
+	p := new(multisigProposal)
+	if err := json.Unmarshal(raw, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func multisigStorageKey(id string) []byte {
+	return []byte(multisigKeyPrefix + id)
+}
+
+func newProposalID() string {
+	raw := make([]byte, 16)
+	_, _ = rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
+
+// multisigStoreFor lazily builds an AdminService's multisigStore from its
+// Neblet's chain storage, so the store is always backed by whichever
+// database the rest of the node is using.
+func (s *AdminService) multisigStoreFor() *multisigStore {
+	return newMultisigStore(s.server.Neblet().Storage())
+}
+
+// CreateMultisigProposalRequest, SignMultisigProposalRequest,
+// GetMultisigProposalRequest and MultisigProposalResponse are new rpcpb
+// messages, declared in the same externally-vendored rpcpb package as every
+// other request/response type AdminService and APIService already depend
+// on — this file adds the handlers, not the package those types live in.
+
+// CreateMultisigProposal is the RPC API handler that opens an M-of-N
+// threshold-signing workflow for req.Transaction: it parses the tx (left
+// unsigned), computes its signing hash, and persists a proposal awaiting
+// req.Threshold signatures from req.Signers before it can be broadcast.
+func (s *AdminService) CreateMultisigProposal(ctx context.Context, req *rpcpb.CreateMultisigProposalRequest) (*rpcpb.MultisigProposalResponse, error) {
+	neb := s.server.Neblet()
+
+	tx, err := parseTransaction(neb, req.Transaction)
+	if err != nil {
+		return nil, err
+	}
+	if int(req.Threshold) > len(req.Signers) || req.Threshold <= 0 {
+		return nil, errors.New("threshold must be between 1 and len(signers)")
+	}
+
+	// Registers tx.From()'s signer set the first time any proposal is
+	// created for it; see core.RegisterMultisigSigners. This is what
+	// TransactionPool.Push's VerifyMultisigIntegrity later binds the
+	// broadcast tx's collected signatures against, so req.Signers/
+	// req.Threshold here must be the address's real authorized signer set,
+	// not whatever BroadcastMultisigProposal's caller happens to supply.
+	if err := core.RegisterMultisigSigners(neb.Storage(), tx.From(), req.Signers, int(req.Threshold)); err != nil {
+		return nil, err
+	}
+
+	pbMsg, err := tx.ToProto()
+	if err != nil {
+		return nil, err
+	}
+	txData, err := proto.Marshal(pbMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry := defaultMultisigExpiry
+	if req.ExpirySeconds > 0 {
+		expiry = time.Duration(req.ExpirySeconds) * time.Second
+	}
+
+	proposal := &multisigProposal{
+		ID:          newProposalID(),
+		TxData:      txData,
+		SigningHash: tx.Hash(),
+		Signers:     req.Signers,
+		Threshold:   int(req.Threshold),
+		ExpiresAt:   time.Now().Add(expiry).Unix(),
+	}
+	if err := s.multisigStoreFor().put(proposal); err != nil {
+		return nil, err
+	}
+
+	return toMultisigProposalResponse(proposal), nil
+}
+
+// SignMultisigProposal is the RPC API handler that contributes one signer's
+// partial signature to a pending proposal, using AccountManager.SignHash so
+// the signer's passphrase-unlocked key never leaves the account keystore. It
+// rejects addresses outside the proposal's signer set, repeat signatures
+// from the same address, and signatures submitted after the proposal has
+// expired.
+func (s *AdminService) SignMultisigProposal(ctx context.Context, req *rpcpb.SignMultisigProposalRequest) (*rpcpb.MultisigProposalResponse, error) {
+	neb := s.server.Neblet()
+	store := s.multisigStoreFor()
+
+	proposal, err := store.get(req.ProposalId)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.expired(time.Now().Unix()) {
+		return nil, ErrProposalExpired
+	}
+	if !proposal.isSigner(req.Address) {
+		return nil, ErrNotASigner
+	}
+	if proposal.hasSigned(req.Address) {
+		return nil, ErrAlreadySigned
+	}
+
+	addr, err := core.AddressParse(req.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := neb.AccountManager().Unlock(addr, []byte(req.Passphrase), keystore.DefaultUnlockDuration); err != nil {
+		return nil, err
+	}
+	sig, err := neb.AccountManager().SignHash(addr, proposal.SigningHash, keystore.SECP256K1)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal.Signatures = append(proposal.Signatures, &core.MultisigSignature{Address: req.Address, Signature: sig})
+	if err := store.put(proposal); err != nil {
+		return nil, err
+	}
+
+	return toMultisigProposalResponse(proposal), nil
+}
+
+// GetMultisigProposal is the RPC API handler returning a proposal's current
+// signer progress.
+func (s *AdminService) GetMultisigProposal(ctx context.Context, req *rpcpb.GetMultisigProposalRequest) (*rpcpb.MultisigProposalResponse, error) {
+	proposal, err := s.multisigStoreFor().get(req.ProposalId)
+	if err != nil {
+		return nil, err
+	}
+	return toMultisigProposalResponse(proposal), nil
+}
+
+// BroadcastMultisigProposal is the RPC API handler that, once a proposal has
+// collected at least its threshold of signatures, assembles them into the
+// tx's signature field via Transaction.ApplyMultisigSignature and hands the
+// result to handleTransactionResponse exactly like a normally-signed
+// transaction.
+func (s *AdminService) BroadcastMultisigProposal(ctx context.Context, req *rpcpb.GetMultisigProposalRequest) (*rpcpb.SendTransactionResponse, error) {
+	neb := s.server.Neblet()
+	proposal, err := s.multisigStoreFor().get(req.ProposalId)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.expired(time.Now().Unix()) {
+		return nil, ErrProposalExpired
+	}
+	if len(proposal.Signatures) < proposal.Threshold {
+		return nil, ErrThresholdNotMet
+	}
+
+	tx, err := proposal.tx()
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.ApplyMultisigSignature(proposal.Signatures, proposal.Threshold); err != nil {
+		return nil, err
+	}
+
+	return handleTransactionResponse(neb, tx)
+}
+
+func toMultisigProposalResponse(p *multisigProposal) *rpcpb.MultisigProposalResponse {
+	addrs := make([]string, 0, len(p.Signatures))
+	for _, s := range p.Signatures {
+		addrs = append(addrs, s.Address)
+	}
+	return &rpcpb.MultisigProposalResponse{
+		ProposalId:    p.ID,
+		SigningHash:   p.SigningHash,
+		Threshold:     int32(p.Threshold),
+		Signers:       p.Signers,
+		SignedBy:      addrs,
+		ExpiresAtUnix: p.ExpiresAt,
+	}
+}
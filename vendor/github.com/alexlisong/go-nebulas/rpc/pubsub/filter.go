@@ -0,0 +1,82 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package pubsub
+
+// LogEvent is the payload a KindLogs subscription receives: one contract
+// execution event emitted by the NVM, as published from
+// core.CallPayload.Execute once engine.Call returns successfully.
+type LogEvent struct {
+	ContractAddress string
+	EventName       string
+	Topic           string
+	Data            string
+	BlockHeight     uint64
+	TxHash          string
+}
+
+// Filter narrows a subscription down server-side, the same way the gRPC
+// SubscribeFilter does for APIService.Subscribe, so a busy chain does not
+// flood a client with events it is only going to discard.
+type Filter struct {
+	// Addresses, when non-empty, only matches logs from one of these
+	// contract addresses.
+	Addresses []string
+
+	// Topics, when non-empty, only matches logs whose Topic is in this set.
+	Topics []string
+
+	// EventName, when non-empty, only matches logs with this exact event
+	// name.
+	EventName string
+}
+
+// Match reports whether data (the value about to be published under kind)
+// satisfies f. Only KindLogs payloads are actually filtered; every other
+// kind passes through unfiltered since KindNewHeads/KindReorgs are not
+// per-contract and KindPendingTxs filtering is handled the same way
+// APIService.Subscribe already does it via core.SubscribeFilter.
+func (f *Filter) Match(kind Kind, data interface{}) bool {
+	if f == nil || kind != KindLogs {
+		return true
+	}
+	log, ok := data.(*LogEvent)
+	if !ok {
+		return true
+	}
+
+	if len(f.Addresses) > 0 && !containsString(f.Addresses, log.ContractAddress) {
+		return false
+	}
+	if len(f.Topics) > 0 && !containsString(f.Topics, log.Topic) {
+		return false
+	}
+	if f.EventName != "" && f.EventName != log.EventName {
+		return false
+	}
+	return true
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
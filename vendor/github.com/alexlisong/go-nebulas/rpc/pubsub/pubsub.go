@@ -0,0 +1,177 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+// Package pubsub implements a JSON-RPC 2.0 subscription layer, modeled on
+// Ethereum's pub/sub design, that sits alongside the existing gRPC
+// AdminService/ApiService. It is transport-agnostic: a WebSocket or
+// bidirectional gRPC stream handler is meant to feed Hub.Subscribe and read
+// each Subscription's channel to push JSON-encoded notifications to the
+// client.
+//
+// NOTE: no such transport handler exists in this tree yet. Attach (see
+// register.go) wires a Hub up to the node's real event sources, so it
+// receives and fans out notifications correctly, but nothing in rpc/
+// exposes it to an external client over HTTP or gRPC — this package is the
+// internal fan-out hub the transport has yet to be built on top of.
+package pubsub
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// Kind identifies what a Subscription was created to observe.
+type Kind string
+
+// Supported subscription kinds.
+const (
+	KindNewHeads   Kind = "newHeads"
+	KindPendingTxs Kind = "newPendingTransactions"
+	KindLogs       Kind = "logs"
+	KindReorgs     Kind = "chainReorgs"
+)
+
+// Notification is a single JSON-encodable event delivered to a Subscription.
+type Notification struct {
+	// Kind is the subscription kind this notification belongs to, so a
+	// transport that multiplexes several subscriptions on one connection can
+	// route it.
+	Kind Kind `json:"kind"`
+
+	// Data is the JSON-marshaled payload: a block header for KindNewHeads, a
+	// tx hash for KindPendingTxs, a log entry for KindLogs, or a reorg
+	// summary for KindReorgs.
+	Data interface{} `json:"data"`
+}
+
+// Subscription is a single client's live subscription. It is torn down,
+// releasing its slot in the Hub, when the client disconnects or explicitly
+// unsubscribes.
+type Subscription struct {
+	// ID is the opaque identifier returned to the client at creation time
+	// and echoed back on every notification so the client can demultiplex
+	// several subscriptions on one connection.
+	ID string
+
+	Kind   Kind
+	Filter *Filter
+
+	ch     chan *Notification
+	hub    *Hub
+	closed bool
+	mu     sync.Mutex
+}
+
+// Notifications returns the channel notifications for this subscription are
+// delivered on. It is closed once Unsubscribe is called.
+func (s *Subscription) Notifications() <-chan *Notification {
+	return s.ch
+}
+
+// Unsubscribe removes the subscription from its Hub and closes its channel.
+// It is safe to call more than once and safe to call concurrently with
+// delivery.
+func (s *Subscription) Unsubscribe() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.hub.remove(s)
+	close(s.ch)
+}
+
+func (s *Subscription) deliver(n *Notification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- n:
+	default:
+		// a slow client must not block block/tx processing; drop the
+		// notification rather than stall the publisher.
+	}
+}
+
+// Hub fans a single stream of chain events out to every live Subscription
+// that wants them, applying each subscription's Filter before delivery.
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[Kind]map[string]*Subscription
+}
+
+// NewHub returns an empty Hub.
+func NewHub() *Hub {
+	return &Hub{subs: make(map[Kind]map[string]*Subscription)}
+}
+
+// Subscribe registers a new Subscription of the given kind and filter,
+// returning it with a freshly minted opaque ID.
+func (h *Hub) Subscribe(kind Kind, filter *Filter) *Subscription {
+	sub := &Subscription{
+		ID:     newSubscriptionID(),
+		Kind:   kind,
+		Filter: filter,
+		ch:     make(chan *Notification, 256),
+		hub:    h,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[kind] == nil {
+		h.subs[kind] = make(map[string]*Subscription)
+	}
+	h.subs[kind][sub.ID] = sub
+	return sub
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if m, ok := h.subs[sub.Kind]; ok {
+		delete(m, sub.ID)
+	}
+}
+
+// Publish delivers data, tagged as kind, to every subscription of that kind
+// whose filter matches it.
+func (h *Hub) Publish(kind Kind, data interface{}) {
+	h.mu.RLock()
+	subs := make([]*Subscription, 0, len(h.subs[kind]))
+	for _, sub := range h.subs[kind] {
+		subs = append(subs, sub)
+	}
+	h.mu.RUnlock()
+
+	for _, sub := range subs {
+		if sub.Filter != nil && !sub.Filter.Match(kind, data) {
+			continue
+		}
+		sub.deliver(&Notification{Kind: kind, Data: data})
+	}
+}
+
+func newSubscriptionID() string {
+	raw := make([]byte, 16)
+	_, _ = rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
@@ -0,0 +1,59 @@
+// Copyright (C) 2017 go-nebulas authors
+//
+// This file is part of the go-nebulas library.
+//
+// the go-nebulas library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// the go-nebulas library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with the go-nebulas library.  If not, see <http://www.gnu.org/licenses/>.
+//
+
+package pubsub
+
+import "github.com/alexlisong/go-nebulas/core"
+
+// Attach wires hub into core.ContractCallHook so every successful contract
+// call is turned into a KindLogs notification, and registers neb's
+// EventEmitter as the source for KindNewHeads/KindReorgs/KindPendingTxs.
+// Call it once at node startup when the pub/sub subsystem is enabled in
+// config; an unattached Hub simply never receives anything, which is how
+// operators disable the whole subsystem.
+func Attach(hub *Hub, neb core.Neblet) {
+	core.ContractCallHook = func(block *core.Block, tx *core.Transaction, contract *core.Address, payload *core.CallPayload) {
+		hub.Publish(KindLogs, &LogEvent{
+			ContractAddress: contract.String(),
+			EventName:       payload.Function,
+			Topic:           payload.Function,
+			TxHash:          tx.Hash().String(),
+			BlockHeight:     block.Height(),
+		})
+	}
+
+	eventSub := core.NewEventSubscriber(1024, []string{
+		core.TopicPendingTransaction,
+		core.TopicChainHead,
+		core.TopicChainReorg,
+	})
+	neb.EventEmitter().Register(eventSub)
+
+	go func() {
+		for event := range eventSub.EventChan() {
+			switch event.Topic {
+			case core.TopicPendingTransaction:
+				hub.Publish(KindPendingTxs, event.Data)
+			case core.TopicChainHead:
+				hub.Publish(KindNewHeads, event.Data)
+			case core.TopicChainReorg:
+				hub.Publish(KindReorgs, event.Data)
+			}
+		}
+	}()
+}